@@ -1,19 +1,85 @@
 package gimc
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // RePol is the type defining Replacement Policies for the cache
 type RePol int
 
 const (
-	FIFO RePol = iota // FIFO = First In First Out
-	LRU               // LRU  = Least Recently Used
+	FIFO   RePol = iota // FIFO   = First In First Out
+	LRU                 // LRU    = Least Recently Used
+	TwoQ                // TwoQ   = 2Q, scan resistant (A1in, A1out, Am)
+	S3FIFO              // S3FIFO = Small, main and ghost FIFOs, scan resistant
+	LIRS                // LIRS   = Low Inter-reference Recency Set, loop resistant
+	SRRIP               // SRRIP  = Static Re-Reference Interval Prediction
+	BRRIP               // BRRIP  = Bimodal Re-Reference Interval Prediction, thrash resistant
+	DRRIP               // DRRIP  = Dynamic Re-Reference Interval Prediction, set dueling between SRRIP and BRRIP
+	OPT                 // OPT    = Belady's optimal policy, needs the trace of the accesses (see WithTrace)
 )
 
+// String gives the name of the replacement policy
+func (p RePol) String() string {
+	switch p {
+	case FIFO:
+		return "FIFO"
+	case LRU:
+		return "LRU"
+	case TwoQ:
+		return "2Q"
+	case S3FIFO:
+		return "S3-FIFO"
+	case LIRS:
+		return "LIRS"
+	case SRRIP:
+		return "SRRIP"
+	case BRRIP:
+		return "BRRIP"
+	case DRRIP:
+		return "DRRIP"
+	case OPT:
+		return "OPT"
+	default:
+		return fmt.Sprintf("RePol(%d)", int(p))
+	}
+}
+
+// MarshalText encodes the replacement policy by its name, in JSON for instance
+func (p RePol) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes the name of a replacement policy (see ParseRePol)
+func (p *RePol) UnmarshalText(text []byte) error {
+	pol, err := ParseRePol(string(text))
+	if err != nil {
+		return err
+	}
+	*p = pol
+	return nil
+}
+
+// ParseRePol gives the replacement policy with this name (see RePol.String), ignoring the case
+func ParseRePol(name string) (RePol, error) {
+	for pol := FIFO; pol <= OPT; pol++ {
+		if strings.EqualFold(name, pol.String()) {
+			return pol, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("CACHE: Unknown replacement policy %s", name))
+}
+
+// Option is an optional setting of the cache, given at its creation
+type Option func(c *Cache) error
+
 type Datasource interface {
 	ReadAt(p []byte, off int64) (n int, err error)
 	WriteAt(p []byte, off int64) (n int, err error)
@@ -21,29 +87,100 @@ type Datasource interface {
 	Close() error
 }
 
+// Cache is safe for concurrent use, the accesses being serialized.
 type Cache struct {
+	mu                    sync.Mutex // serializes the accesses
 	sets                  []*set
 	indexMask, offsetMask uint32 // Up to 16 bits of offset (max 2^16 blockSize)
 	offsetSize, tagSize   uint8  // max 255 (address max 32 bits..., way too much as in fully associative it is 32 bits max)
 	source                Datasource
-	hitCount, missCount   uint64
-	blockSize, dataSize   uint16 // max 65_535 byte for a single data (same as block size)
-	maxWays               uint16 // max 65_535, number of maximum ways
+	instrumented          *InstrumentedDatasource // source if it is instrumented, to time the misses
+	blockSize, dataSize   uint16                  // max 65_535 byte for a single data (same as block size)
+	maxWays               uint16                  // max 65_535, number of maximum ways
 	repol                 RePol
+	duel                  *duel                // shared by the sets when the policy uses set dueling
+	admission             *tinyLFU             // optional admission filter, nil if every missed block is admitted
+	trace                 *optTrace            // future accesses, only for OPT
+	rival                 *RePol               // policy dueling with repol, nil without set dueling
+	setConfigs            map[uint32]setConfig // sets with their own policy, by index
+	regions               []*region            // ranges of addresses with their own sets
+	classifier            *missClassifier      // optional classification of the misses
+	hooks                 []Hooks              // callbacks on the events of the cache
+	shadows               []*shadow            // tag-only caches fed with the same addresses
+	heatmap               *heatmap             // optional activity of the sets over time
+	tracker               *lineTracker         // optional hits of the lines and last evictions
+	logger                *slog.Logger
+	debug                 bool // tells if the debug messages are logged, updated at each access
+}
+
+// WithSetDueling makes the replacement policy of the cache duel with the rival policy.
+// A few leader sets always use one of the two policies and the other sets follow the policy
+// whose leaders miss less, switching at runtime when the workload changes. The followers keep the state
+// of both policies up to date, a switch does not lose what the policy learnt about the set.
+// DRRIP cannot be used with set dueling as it is already dueling.
+func WithSetDueling(rival RePol) Option {
+	return func(c *Cache) error {
+		if rival == DRRIP || c.repol == DRRIP {
+			return errors.New("DRRIP already uses set dueling")
+		}
+		c.rival = &rival
+		return nil
+	}
+}
+
+// WithLogger logs the messages of the cache with this logger instead of slog.Default().
+// At the debug level, every hit, miss and eviction is logged with the set, its policy, the tag and the address.
+// Nothing is computed for the debug messages when the level is disabled.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Cache) error {
+		if logger == nil {
+			return errors.New("the logger is missing")
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithTinyLFU puts a TinyLFU admission filter in front of the replacement policy.
+// A missed block replaces the victim chosen by the policy only if it has been accessed more frequently,
+// otherwise the data are returned without being cached.
+func WithTinyLFU() Option {
+	return func(c *Cache) error {
+		c.admission = newTinyLFU(uint32(len(c.sets)) * uint32(c.maxWays))
+		return nil
+	}
 }
 
 // CreateCache create a new cache regarding the options given
-func CreateCache(sets, blockSize, dataSize, ways uint16, source Datasource, pol RePol) (*Cache, error) {
-	// Simple verification for the parameters
-	if dataSize > blockSize || dataSize == 0 || blockSize%dataSize != 0 {
-		return nil, errors.New("CACHE: The given data are not good")
+func CreateCache(sets, blockSize, dataSize, ways uint16, source Datasource, pol RePol, opts ...Option) (*Cache, error) {
+	if source == nil {
+		return nil, errors.New("CACHE: The datasource is missing")
+	}
+	c, err := newCache(sets, blockSize, dataSize, ways, source, pol, opts...)
+	if err != nil {
+		return nil, err
 	}
 
 	// open the source
-	err := source.Open()
+	err = source.Open()
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("CACHE: Cannot open the datasource: %s", err))
 	}
+	return c, nil
+}
+
+// newCache creates the cache without opening the source.
+// If the source is nil, the cache only keeps the tags (for simulations) and Get returns nil.
+func newCache(sets, blockSize, dataSize, ways uint16, source Datasource, pol RePol, opts ...Option) (*Cache, error) {
+	// Simple verification for the parameters
+	if dataSize > blockSize || dataSize == 0 || blockSize%dataSize != 0 {
+		return nil, errors.New("CACHE: The given data are not good")
+	}
+	if !isPowerOfTwo(sets) || !isPowerOfTwo(blockSize) || ways == 0 {
+		return nil, errors.New(fmt.Sprintf(
+			"CACHE: Invalid geometry of %d sets of %d ways of %d bytes, sets and block size must be powers of 2", sets, ways, blockSize,
+		))
+	}
 
 	// Calculate the different size
 	indexSize := uint8(math.Log2(float64(sets)))
@@ -57,36 +194,207 @@ func CreateCache(sets, blockSize, dataSize, ways uint16, source Datasource, pol
 		offsetSize: offsetSize,
 		tagSize:    tagSize,
 		source:     source,
-		hitCount:   0,
-		missCount:  0,
 		blockSize:  blockSize,
 		dataSize:   dataSize,
 		maxWays:    ways,
 		repol:      pol,
+		logger:     slog.Default(),
+	}
+	c.instrumented, _ = source.(*InstrumentedDatasource)
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, errors.New(fmt.Sprintf("CACHE: Invalid option: %s", err))
+		}
+	}
+
+	if err := c.checkPolicy(pol); err != nil {
+		return nil, err
+	}
+	if c.rival != nil {
+		if err := c.checkPolicy(*c.rival); err != nil {
+			return nil, err
+		}
+	}
+	for _, config := range c.setConfigs {
+		if err := c.checkPolicy(config.pol); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range c.regions {
+		if err := c.checkPolicy(r.config.pol); err != nil {
+			return nil, err
+		}
+	}
+	if c.duel == nil && c.rival != nil {
+		c.duel = newDuel(sets)
 	}
 
 	// Create the sets
 	for i := uint16(0); i < sets; i++ {
-		c.sets[i] = createSet(c)
+		config, ok := c.setConfigs[uint32(i)]
+		if !ok {
+			config = setConfig{pol: pol, ways: ways}
+		}
+		c.sets[i] = createSet(c, uint32(i), config, !ok && c.rival != nil)
+	}
+	for _, r := range c.regions {
+		r.sets = make([]*set, sets)
+		for i := uint16(0); i < sets; i++ {
+			r.sets[i] = createSet(c, uint32(i), r.config, false)
+		}
+	}
+	if c.classifier != nil {
+		c.classifier.maxLines = c.capacity()
 	}
 	return c, nil
 }
 
-// Get data at this address using the cache
+// checkPolicy verifies that the cache has what the replacement policy needs
+func (c *Cache) checkPolicy(pol RePol) error {
+	switch pol {
+	case DRRIP:
+		if c.rival != nil {
+			return errors.New("CACHE: DRRIP cannot be used with set dueling")
+		}
+		if c.duel == nil {
+			c.duel = newDuel(uint16(len(c.sets)))
+		}
+	case OPT:
+		if c.trace == nil {
+			return errors.New("CACHE: The OPT policy needs the trace of the accesses, use WithTrace")
+		}
+	case FIFO, LRU, TwoQ, S3FIFO, LIRS, SRRIP, BRRIP:
+	default:
+		return errors.New(fmt.Sprintf("CACHE: Unknown replacement policy %d", pol))
+	}
+	return nil
+}
+
+// capacity gives the number of blocks the cache can hold
+func (c *Cache) capacity() int {
+	lines := 0
+	for _, s := range c.sets {
+		lines += int(s.maxWays)
+	}
+	for _, r := range c.regions {
+		lines += len(r.sets) * int(r.config.ways)
+	}
+	return lines
+}
+
+// Get data at this address using the cache.
+// If the block cannot be read from the datasource, the error is logged and nil is returned (see Read).
 func (c *Cache) Get(address uint32) []byte {
+	data, _, err := c.get(address)
+	if err != nil {
+		c.logReadError(address, err)
+	}
+	return data
+}
+
+func (c *Cache) logReadError(address uint32, err error) {
+	c.logger.Error("cannot read the block", addressAttr(address), slog.String("error", err.Error()))
+}
+
+// Read gives the data at this address using the cache, or the error of the datasource if the block
+// cannot be read. A block that cannot be read is not cached.
+func (c *Cache) Read(address uint32) ([]byte, error) {
+	data, _, err := c.get(address)
+	return data, err
+}
+
+// get gives the data at this address using the cache and tells if it was a hit
+func (c *Cache) get(address uint32) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.debug = c.logger.Enabled(context.Background(), slog.LevelDebug)
 	// get last 9 bits for index
 	index := (address >> c.offsetSize) & c.indexMask
-	return c.sets[index].get(address)
+	if c.trace != nil {
+		c.trace.step(address & ^c.offsetMask)
+	}
+	if c.heatmap != nil {
+		c.heatmap.access(index)
+	}
+	data, hit, err := c.setsOf(address)[index].get(address)
+	if c.classifier != nil {
+		c.classifier.access(address & ^c.offsetMask, hit)
+	}
+	for _, s := range c.shadows {
+		s.cache.get(address)
+	}
+	return data, hit, err
+}
+
+// Invalidate removes the block holding this address from the cache, it returns false if the block was not cached.
+// The removal is not counted as an eviction and the hooks are not called. With WithMissClassification,
+// the next miss on the block is compulsory.
+func (c *Cache) Invalidate(address uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index := (address >> c.offsetSize) & c.indexMask
+	if !c.setsOf(address)[index].invalidate(address) {
+		return false
+	}
+	if c.classifier != nil {
+		c.classifier.forget(address & ^c.offsetMask)
+	}
+	return true
+}
+
+// Flush removes all the blocks from the cache. The statistics are kept (see ResetCounters).
+// With WithMissClassification, the next miss on each block is compulsory.
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.sets {
+		s.flush()
+	}
+	for _, r := range c.regions {
+		for _, s := range r.sets {
+			s.flush()
+		}
+	}
+	if c.tracker != nil {
+		c.tracker.hits = make(map[uint32]uint64)
+	}
+	if c.classifier != nil {
+		c.classifier.flush()
+	}
 }
 
-// ResetCounters resets the hits and misses counters
+// duelPolicy gives the policy to use by a set with this role in the set dueling
+func (c *Cache) duelPolicy(role int) RePol {
+	if c.duel.useB(role) {
+		return *c.rival
+	}
+	return c.repol
+}
+
+// ResetCounters resets the counters of the statistics
 func (c *Cache) ResetCounters() {
-	c.hitCount = 0
-	c.missCount = 0
+	for _, s := range c.sets {
+		s.stats.reset()
+	}
+	for _, r := range c.regions {
+		for _, s := range r.sets {
+			s.stats.reset()
+		}
+	}
+	if c.classifier != nil {
+		c.classifier.reset()
+	}
+	for _, s := range c.shadows {
+		s.cache.ResetCounters()
+	}
 }
 
 // Close closes the cache and the datasource
 func (c *Cache) Close() error {
+	if c.source == nil {
+		return nil
+	}
 	err := c.source.Close()
 	if err != nil {
 		return errors.New(fmt.Sprintf("CACHE: Cannot close the source: %s", err))
@@ -94,9 +402,19 @@ func (c *Cache) Close() error {
 	return nil
 }
 
-// GetCounters gives counter of hits and misses of the cache
+// GetCounters gives counter of hits and misses of the cache, without allocating as Stats does
 func (c *Cache) GetCounters() (hits, misses uint64) {
-	return c.hitCount, c.missCount
+	add := func(sets []*set) {
+		for _, s := range sets {
+			hits += atomic.LoadUint64(&s.stats.hits)
+			misses += atomic.LoadUint64(&s.stats.misses)
+		}
+	}
+	add(c.sets)
+	for _, r := range c.regions {
+		add(r.sets)
+	}
+	return hits, misses
 }
 
 // CalculateMask generates a mask (1 at the LSB)
@@ -8,6 +8,7 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"sort"
@@ -21,25 +22,30 @@ var keepMMU *bst.BST
 
 type HashList [][32]byte
 
-func TestCreateData(t *testing.T) {
-	file, err := os.Create("hashes.txt")
-	defer file.Close()
-	if err != nil {
-		log.Fatalln("Cannot create file")
-	}
-	for i := 0; i < 2_000_000; i++ {
+// hashCount is the number of hashes of the test data
+const hashCount = 2_000_000
+
+// hashData gives the sha256 of the numbers from 0 to hashCount, one after the other
+func hashData() []byte {
+	data := make([]byte, 0, hashCount*sha256.Size)
+	for i := 0; i < hashCount; i++ {
 		sum256 := sha256.Sum256([]byte(strconv.Itoa(i)))
-		_, err = file.Write(sum256[:])
-		if err != nil {
-			log.Fatalln("Error while writing")
-		}
+		data = append(data, sum256[:]...)
 	}
+	return data
+}
 
+// writeHashes writes the hashes of hashData in a temporary file and gives its path
+func writeHashes(tb testing.TB) string {
+	path := filepath.Join(tb.TempDir(), "hashes.txt")
+	if err := os.WriteFile(path, hashData(), 0644); err != nil {
+		tb.Fatal(fmt.Sprintf("Cannot write the hashes: %s", err))
+	}
+	return path
 }
 
 func TestGet(t *testing.T) {
-	fd := NewFileDatasource("hashes.txt")
-	cache, err := CreateCache(1024, 4096, 32, 1, fd, FIFO)
+	cache, err := CreateCache(1024, 4096, 32, 1, NewMemoryDatasource(hashData()), FIFO)
 	if err != nil {
 		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
 	}
@@ -50,7 +56,7 @@ func TestGet(t *testing.T) {
 		}
 	}(cache)
 	for i := 0; i < 5_000_000; i++ {
-		random := rand.Intn(2_000_000)
+		random := rand.Intn(hashCount)
 		// conversion ok, max 2 mio
 		sum256 := sha256.Sum256([]byte(strconv.Itoa(random)))
 		get := cache.Get(uint32(random) * 32)
@@ -61,8 +67,24 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestFileDatasource(t *testing.T) {
+	fd := NewFileDatasource(writeHashes(t))
+	cache, err := CreateCache(64, 4096, 32, 2, fd, LRU)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	defer cache.Close()
+	for i := 0; i < 100_000; i++ {
+		random := rand.Intn(hashCount)
+		sum256 := sha256.Sum256([]byte(strconv.Itoa(random)))
+		if bytes.Compare(cache.Get(uint32(random)*32), sum256[:]) != 0 {
+			t.Fatal("Not same sha")
+		}
+	}
+}
+
 func BenchmarkGetWithCache(b *testing.B) {
-	fd := NewFileDatasource("hashes.txt")
+	fd := NewFileDatasource(writeHashes(b))
 	keepCache, _ = CreateCache(512, 4096, 32, 1, fd, FIFO)
 	defer keepCache.Close()
 	keepCache.ResetCounters()
@@ -71,7 +93,7 @@ func BenchmarkGetWithCache(b *testing.B) {
 			b.ReportAllocs()
 			b.ResetTimer()
 			for i := 0; i < b.N; i++ {
-				keepCache.Get(uint32(i%2_000_000) * 32) // data are 32 bytes long
+				keepCache.Get(uint32(i%hashCount) * 32) // data are 32 bytes long
 			}
 		},
 	)
@@ -83,25 +105,25 @@ func BenchmarkGetWithCache(b *testing.B) {
 }
 
 func BenchmarkGetWithoutCache(b *testing.B) {
-	fd := NewFileDatasource("hashes.txt")
+	fd := NewFileDatasource(writeHashes(b))
 	fd.Open()
 	defer fd.Close()
 	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		var data [32]byte // 32 bytes data
-		fd.ReadAt(data[:], int64(i%2_000_000)*32)
+		fd.ReadAt(data[:], int64(i%hashCount)*32)
 	}
 }
 
 func BenchmarkGetWithoutCache2(b *testing.B) {
-	file, _ := os.Open("hashes.txt")
+	file, _ := os.Open(writeHashes(b))
 	defer file.Close()
 	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		var data [32]byte // 32 bytes data
-		file.ReadAt(data[:], int64(i%2_000_000)*32)
+		file.ReadAt(data[:], int64(i%hashCount)*32)
 	}
 }
 
@@ -0,0 +1,99 @@
+package gimc
+
+import "sync/atomic"
+
+// missClassifier classifies the misses of the cache in the three C:
+//   - compulsory: the block has never been accessed before,
+//   - capacity: a fully associative LRU cache of the same capacity would also have missed,
+//   - conflict: a fully associative LRU cache of the same capacity would have hit.
+//
+// Many capacity misses mean the cache is too small, many conflict misses mean it lacks ways.
+type missClassifier struct {
+	compulsory, capacity, conflict uint64 // counters, first for their alignment
+
+	seen     map[uint32]struct{} // every block ever accessed
+	shadow   *tagList            // fully associative LRU of the blocks, front is the least recently used
+	maxLines int                 // capacity of the shadow, in blocks
+}
+
+// WithMissClassification classifies the misses as compulsory, capacity or conflict misses (see GetMissClasses).
+// It keeps every block address ever accessed and a fully associative LRU of the size of the cache.
+func WithMissClassification() Option {
+	return func(c *Cache) error {
+		c.classifier = &missClassifier{
+			seen:   make(map[uint32]struct{}),
+			shadow: newTagList(),
+		}
+		return nil
+	}
+}
+
+// access must be called for every access of the cache with the address of the block and if the cache hit
+func (m *missClassifier) access(block uint32, hit bool) {
+	_, seen := m.seen[block]
+	shadowHit := false
+	if n := m.shadow.get(block); n != nil {
+		shadowHit = true
+		m.shadow.moveToBack(n)
+	} else {
+		if m.shadow.len() >= m.maxLines {
+			m.shadow.popFront()
+		}
+		m.shadow.pushBack(block)
+	}
+	if hit {
+		return
+	}
+	switch {
+	case !seen:
+		m.seen[block] = struct{}{}
+		atomic.AddUint64(&m.compulsory, 1)
+	case !shadowHit:
+		atomic.AddUint64(&m.capacity, 1)
+	default:
+		atomic.AddUint64(&m.conflict, 1)
+	}
+}
+
+// forget forgets an invalidated block, its next miss is compulsory as it must be read again whatever the cache
+func (m *missClassifier) forget(block uint32) {
+	delete(m.seen, block)
+	m.shadow.remove(block)
+}
+
+// flush forgets all the blocks of a flushed cache
+func (m *missClassifier) flush() {
+	m.seen = make(map[uint32]struct{})
+	m.shadow = newTagList()
+}
+
+func (m *missClassifier) snapshot() MissClasses {
+	return MissClasses{
+		Compulsory: atomic.LoadUint64(&m.compulsory),
+		Capacity:   atomic.LoadUint64(&m.capacity),
+		Conflict:   atomic.LoadUint64(&m.conflict),
+	}
+}
+
+func (m *missClassifier) reset() {
+	atomic.StoreUint64(&m.compulsory, 0)
+	atomic.StoreUint64(&m.capacity, 0)
+	atomic.StoreUint64(&m.conflict, 0)
+}
+
+// MissClasses are the misses of the cache classified in compulsory, capacity and conflict misses
+type MissClasses struct {
+	Compulsory uint64 `json:"compulsory"`
+	Capacity   uint64 `json:"capacity"`
+	Conflict   uint64 `json:"conflict"`
+}
+
+// GetMissClasses gives the misses of the cache classified in compulsory, capacity and conflict misses.
+// The cache must have been created WithMissClassification, otherwise they are all 0.
+func (c *Cache) GetMissClasses() (compulsory, capacity, conflict uint64) {
+	if c.classifier == nil {
+		return 0, 0, 0
+	}
+	classes := c.classifier.snapshot()
+	return classes.Compulsory, classes.Capacity, classes.Conflict
+}
@@ -0,0 +1,260 @@
+// Command gimcsim replays a trace of addresses on a cache built from its flags and prints its statistics.
+//
+// The data are read from a synthetic datasource, only the behaviour of the cache is simulated.
+// The trace can be in the Dinero IV din format, one hexadecimal address per line or the binary format of
+// gimc (see package trace):
+//
+//	gimcsim -sets 512 -ways 8 -block 64 -policy lru -trace accesses.din
+//
+// When sets, ways, block or policy are lists of values separated by commas, every combination is replayed
+// in parallel and a table of the results is printed (Markdown by default, CSV with -out csv).
+// -classify and -heatmap detail a single cache and are refused with a sweep:
+//
+//	gimcsim -sets 256,512,1024 -ways 4,8,16 -block 64 -policy lru,s3-fifo -out csv -trace accesses.din
+//
+// With -mrc, the reuse distances of the trace are analyzed instead and the miss ratio of fully associative
+// LRU caches is printed for sizes doubling up to the working set, for each block size:
+//
+//	gimcsim -mrc -block 32,64 -sampling 0.01 -trace accesses.din
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ag0st/gimc"
+	"github.com/ag0st/gimc/pkg/reuse"
+	"github.com/ag0st/gimc/pkg/trace"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run parses the arguments, replays the trace and writes the statistics in out
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("gimcsim", flag.ContinueOnError)
+	sets := flags.String("sets", "512", "number of sets, power of 2")
+	ways := flags.String("ways", "8", "number of ways of each set")
+	blockSize := flags.String("block", "64", "size of a block in bytes, power of 2")
+	policy := flags.String("policy", "LRU", "replacement policy (FIFO, LRU, 2Q, S3-FIFO, LIRS, SRRIP, BRRIP, DRRIP, OPT)")
+	tinyLFU := flags.Bool("tinylfu", false, "use the TinyLFU admission filter")
+	classify := flags.Bool("classify", false, "classify the misses in compulsory, capacity and conflict misses")
+	heatmap := flags.Uint64("heatmap", 0, "print the activity of the sets by window of this number of accesses and the hottest sets")
+	tracePath := flags.String("trace", "", "trace to replay, - for the standard input")
+	format := flags.String("format", "auto", "format of the trace: din, hex, gimc or auto (from the extension and content)")
+	output := flags.String("out", "", "run a sweep and print its table as md or csv, implied by lists of values")
+	mrc := flags.Bool("mrc", false, "print the miss-ratio curve of the trace instead of simulating a cache")
+	sampling := flags.Float64("sampling", 1, "fraction of the blocks analyzed by -mrc, in (0, 1]")
+	workers := flags.Int("workers", 0, "number of parallel simulations of a sweep, the number of CPUs by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *tracePath == "" {
+		return errors.New("GIMCSIM: -trace is required")
+	}
+	grid := gimc.Grid{}
+	var err error
+	if grid.Sets, err = parseSizes("sets", *sets); err != nil {
+		return err
+	}
+	if grid.Ways, err = parseSizes("ways", *ways); err != nil {
+		return err
+	}
+	if grid.BlockSizes, err = parseSizes("block", *blockSize); err != nil {
+		return err
+	}
+	for _, name := range strings.Split(*policy, ",") {
+		pol, err := gimc.ParseRePol(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		grid.Policies = append(grid.Policies, pol)
+	}
+	geometries := grid.Geometries()
+	sweeping := len(geometries) > 1 || *output != ""
+	if sweeping && !*mrc && (*classify || *heatmap > 0) {
+		return errors.New("GIMCSIM: -classify and -heatmap print the details of a single cache, they cannot be used with a sweep")
+	}
+	addresses, err := readTrace(*tracePath, *format)
+	if err != nil {
+		return err
+	}
+
+	if *mrc {
+		return curves(out, addresses, grid.BlockSizes, *sampling)
+	}
+
+	var opts []gimc.Option
+	if *tinyLFU {
+		opts = append(opts, gimc.WithTinyLFU())
+	}
+	if *heatmap > 0 {
+		opts = append(opts, gimc.WithHeatmap(*heatmap, len(addresses)/int(*heatmap)+1))
+	}
+	if sweeping {
+		return sweep(out, addresses, grid, *workers, *output, opts)
+	}
+	return simulate(out, addresses, geometries[0], *classify, opts)
+}
+
+// simulate replays the trace on a single cache and writes its statistics in out
+func simulate(out io.Writer, addresses []uint32, g gimc.Geometry, classify bool, opts []gimc.Option) error {
+	pol := g.Policy
+	if classify {
+		opts = append(opts, gimc.WithMissClassification())
+	}
+	if pol == gimc.OPT {
+		opts = append(opts, gimc.WithTrace(addresses))
+	}
+	cache, err := gimc.CreateCache(g.Sets, g.BlockSize, 1, g.Ways, syntheticSource{}, pol, opts...)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+	for _, address := range addresses {
+		cache.Get(address)
+	}
+
+	stats := cache.Stats()
+	accesses := stats.Hits + stats.Misses
+	fmt.Fprintf(out, "geometry    %d sets x %d ways x %d bytes, %s\n", g.Sets, g.Ways, g.BlockSize, pol)
+	fmt.Fprintf(out, "accesses    %d\n", accesses)
+	fmt.Fprintf(out, "hits        %d\n", stats.Hits)
+	fmt.Fprintf(out, "misses      %d\n", stats.Misses)
+	fmt.Fprintf(out, "evictions   %d\n", stats.Evictions)
+	if accesses > 0 {
+		fmt.Fprintf(out, "hit ratio   %.4f\n", float64(stats.Hits)/float64(accesses))
+		fmt.Fprintf(out, "misses/ka   %.2f\n", float64(stats.Misses)*1000/float64(accesses))
+	}
+	if stats.Classes != nil {
+		fmt.Fprintf(out, "compulsory  %d\n", stats.Classes.Compulsory)
+		fmt.Fprintf(out, "capacity    %d\n", stats.Classes.Capacity)
+		fmt.Fprintf(out, "conflict    %d\n", stats.Classes.Conflict)
+	}
+	if hot := cache.HotSets(5); hot != nil {
+		fmt.Fprintf(out, "hot sets   ")
+		for _, s := range hot {
+			fmt.Fprintf(out, " %d (x%.1f)", s.Index, s.Load)
+		}
+		fmt.Fprintln(out)
+		return cache.Heatmap().WriteASCII(out, 32, false)
+	}
+	return nil
+}
+
+// sweep replays the trace on every geometry of the grid and writes the table of the results in out
+func sweep(out io.Writer, addresses []uint32, grid gimc.Grid, workers int, output string, opts []gimc.Option) error {
+	results, err := gimc.Sweep(addresses, grid, workers, opts...)
+	if err != nil {
+		return err
+	}
+	switch output {
+	case "", "md":
+		return gimc.WriteSweepMarkdown(out, results)
+	case "csv":
+		return gimc.WriteSweepCSV(out, results)
+	default:
+		return errors.New(fmt.Sprintf("GIMCSIM: Unknown output %s, use md or csv", output))
+	}
+}
+
+// curves writes the miss-ratio curve of the trace for each block size in out
+func curves(out io.Writer, addresses []uint32, blockSizes []uint16, rate float64) error {
+	for _, blockSize := range blockSizes {
+		h, err := reuse.Analyze(addresses, uint32(blockSize), reuse.WithSampling(rate))
+		if err != nil {
+			return err
+		}
+		curve := h.MissRatioCurve()
+		fmt.Fprintf(out, "block %d bytes, %d accesses analyzed, %d cold\n", blockSize, h.Accesses, h.Cold)
+		fmt.Fprintf(out, "%12s %14s %10s\n", "blocks", "bytes", "miss ratio")
+		for blocks := 1; ; blocks *= 2 {
+			last := blocks >= len(curve)-1
+			if last {
+				blocks = len(curve) - 1
+			}
+			fmt.Fprintf(out, "%12d %14d %10.4f\n", blocks, blocks*int(blockSize), curve[blocks])
+			if last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseSizes parses a list of sizes of 16 bits separated by commas
+func parseSizes(name, list string) ([]uint16, error) {
+	var sizes []uint16
+	for _, s := range strings.Split(list, ",") {
+		size, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+		if err != nil || size == 0 {
+			return nil, errors.New(fmt.Sprintf("GIMCSIM: Wrong %s %s, must be between 1 and 65535", name, s))
+		}
+		sizes = append(sizes, uint16(size))
+	}
+	return sizes, nil
+}
+
+// readTrace reads the addresses of the trace at path in the given format
+func readTrace(path, format string) ([]uint32, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("GIMCSIM: Cannot open the trace: %s", err))
+		}
+		defer file.Close()
+		r = file
+	}
+	if format == "auto" {
+		var err error
+		format, r, err = detectFormat(path, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return trace.ReadAddresses(r, format)
+}
+
+// detectFormat guesses the format of the trace from the extension of its path and from its first bytes
+func detectFormat(path string, r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, len(trace.Magic))
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, errors.New(fmt.Sprintf("GIMCSIM: Cannot read the trace: %s", err))
+	}
+	head = head[:n]
+	r = io.MultiReader(strings.NewReader(string(head)), r)
+	switch {
+	case string(head) == trace.Magic:
+		return trace.FormatGimc, r, nil
+	case strings.EqualFold(filepath.Ext(path), ".din"):
+		return trace.FormatDin, r, nil
+	default:
+		return trace.FormatHex, r, nil
+	}
+}
+
+// syntheticSource is an endless datasource giving the low byte of the offset of each byte
+type syntheticSource struct{}
+
+func (syntheticSource) ReadAt(p []byte, off int64) (n int, err error) {
+	for i := range p {
+		p[i] = byte(off + int64(i))
+	}
+	return len(p), nil
+}
+
+func (syntheticSource) WriteAt(p []byte, off int64) (n int, err error) { return len(p), nil }
+func (syntheticSource) Open() error                                    { return nil }
+func (syntheticSource) Close() error                                   { return nil }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ag0st/gimc/pkg/trace"
+)
+
+// writeTraces writes the same trace of 3 blocks accessed twice in every format, gives the paths by format
+func writeTraces(t *testing.T) map[string]string {
+	dir := t.TempDir()
+	addresses := []uint32{0x0, 0x40, 0x1000, 0x8, 0x48, 0x1010}
+	var hex, din strings.Builder
+	var bin bytes.Buffer
+	w := trace.NewWriter(&bin)
+	for _, address := range addresses {
+		fmt.Fprintf(&hex, "0x%x\n", address)
+		fmt.Fprintf(&din, "0 %x 4\n", address)
+		_ = w.Write(trace.Record{Address: address, Size: 4, Time: time.Now()})
+	}
+	_ = w.Flush()
+	paths := map[string]string{
+		trace.FormatHex:  filepath.Join(dir, "trace.txt"),
+		trace.FormatDin:  filepath.Join(dir, "trace.din"),
+		trace.FormatGimc: filepath.Join(dir, "trace.bin"),
+	}
+	_ = os.WriteFile(paths[trace.FormatHex], []byte(hex.String()), 0644)
+	_ = os.WriteFile(paths[trace.FormatDin], []byte(din.String()), 0644)
+	_ = os.WriteFile(paths[trace.FormatGimc], bin.Bytes(), 0644)
+	return paths
+}
+
+func TestRun(t *testing.T) {
+	for format, path := range writeTraces(t) {
+		for _, f := range []string{"auto", format} {
+			var out bytes.Buffer
+			err := run([]string{"-sets", "4", "-ways", "2", "-block", "64", "-format", f, "-trace", path}, &out)
+			if err != nil {
+				t.Fatal(fmt.Sprintf("Cannot simulate %s as %s: %s", path, f, err))
+			}
+			for _, line := range []string{"accesses    6\n", "hits        3\n", "misses      3\n", "hit ratio   0.5000\n"} {
+				if !strings.Contains(out.String(), line) {
+					t.Fatal(fmt.Sprintf("Missing %q for %s as %s in:\n%s", line, path, f, out.String()))
+				}
+			}
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	paths := writeTraces(t)
+	for _, args := range [][]string{
+		{},
+		{"-trace", paths[trace.FormatHex], "-policy", "random"},
+		{"-trace", paths[trace.FormatGimc], "-format", "din"},
+		{"-trace", filepath.Join(t.TempDir(), "missing")},
+	} {
+		if err := run(args, &bytes.Buffer{}); err == nil {
+			t.Fatal(fmt.Sprintf("Must fail with %v", args))
+		}
+	}
+}
+
+func TestRunSweep(t *testing.T) {
+	path := writeTraces(t)[trace.FormatHex]
+	var out bytes.Buffer
+	err := run([]string{"-sets", "1,4", "-ways", "2", "-block", "64", "-policy", "lru,opt", "-out", "csv", "-trace", path}, &out)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot sweep: %s", err))
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "sets,ways,block,policy") {
+		t.Fatal(fmt.Sprintf("Wrong table:\n%s", out.String()))
+	}
+	if !strings.Contains(out.String(), "4,2,64,LRU,3,3,") {
+		t.Fatal(fmt.Sprintf("Missing the row of 4 sets with LRU in:\n%s", out.String()))
+	}
+	if err := run([]string{"-sets", "3,4", "-trace", path}, &bytes.Buffer{}); err == nil {
+		t.Fatal("Must fail with a number of sets not a power of 2")
+	}
+	for _, flag := range [][]string{{"-classify"}, {"-heatmap", "10"}} {
+		if err := run(append([]string{"-sets", "1,4", "-trace", path}, flag...), &bytes.Buffer{}); err == nil {
+			t.Fatal(fmt.Sprintf("Must refuse %s with a sweep", flag[0]))
+		}
+	}
+}
+
+func TestRunCurve(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"-mrc", "-block", "64", "-trace", writeTraces(t)[trace.FormatDin]}, &out)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot compute the curve: %s", err))
+	}
+	// 3 blocks accessed twice, a cache of 3 blocks only misses the first accesses
+	for _, line := range []string{"6 accesses analyzed, 3 cold", "           1             64     1.0000\n", "           3            192     0.5000\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatal(fmt.Sprintf("Missing %q in:\n%s", line, out.String()))
+		}
+	}
+}
+
+func TestRunHeatmap(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"-sets", "4", "-ways", "2", "-block", "64", "-heatmap", "2", "-trace", writeTraces(t)[trace.FormatHex]}, &out)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot simulate: %s", err))
+	}
+	// sets 0 and 1, then 0 twice, then 1 and 0
+	for _, line := range []string{"hot sets    0 (x2.7) 1 (x1.3) 2 (x0.0)", "    0 |=@=|\n", "    1 |= =|\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatal(fmt.Sprintf("Missing %q in:\n%s", line, out.String()))
+		}
+	}
+}
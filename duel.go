@@ -0,0 +1,76 @@
+package gimc
+
+// roles of a set in a duel
+const (
+	duelFollower = iota // uses the policy winning the duel
+	duelLeaderA         // always uses the first policy
+	duelLeaderB         // always uses the second policy
+)
+
+const (
+	duelLeaders = 32                // maximum number of leader sets for each policy
+	pselMax     = uint16(1<<10 - 1) // psel is a 10 bits saturating counter
+)
+
+// duel implements set dueling between two policies A and B: a few leader sets always use one of them
+// and a saturating counter (psel) is moved by the misses of the leaders. The follower sets use the
+// policy whose leaders miss less.
+type duel struct {
+	psel   uint16 // incremented on misses of A leaders, decremented on misses of B leaders
+	period uint16 // distance between two leader sets of the same policy
+}
+
+// newDuel creates a duel for a cache of the given number of sets. Small caches have fewer leaders,
+// at most a quarter of the sets for each policy, so that half of the sets at least follow the winner.
+func newDuel(sets uint16) *duel {
+	leaders := sets / 4
+	if leaders > duelLeaders {
+		leaders = duelLeaders
+	}
+	if leaders == 0 {
+		leaders = 1
+	}
+	period := sets / leaders
+	return &duel{
+		psel:   pselMax / 2,
+		period: period,
+	}
+}
+
+// role gives the role in the duel of the set at index
+func (d *duel) role(index uint32) int {
+	switch index % uint32(d.period) {
+	case 0:
+		return duelLeaderA
+	case uint32(d.period) - 1:
+		return duelLeaderB
+	default:
+		return duelFollower
+	}
+}
+
+// miss must be called when a set with the given role misses
+func (d *duel) miss(role int) {
+	switch role {
+	case duelLeaderA:
+		if d.psel < pselMax {
+			d.psel++
+		}
+	case duelLeaderB:
+		if d.psel > 0 {
+			d.psel--
+		}
+	}
+}
+
+// useB tells if a set with the given role must use the policy B
+func (d *duel) useB(role int) bool {
+	switch role {
+	case duelLeaderA:
+		return false
+	case duelLeaderB:
+		return true
+	default:
+		return d.psel > pselMax/2
+	}
+}
@@ -13,6 +13,11 @@ func (f *fifo) toReplace() uint32 {
 	return first
 }
 
+// victim gives the oldest tag, the next one to replace
+func (f *fifo) victim() uint32 {
+	return f.order[0]
+}
+
 // Must be called when hit, part of the replacement algorithm
 func (f *fifo) hit(tag uint32) {
  // nothing to do for FIFO
@@ -23,3 +28,22 @@ func (f *fifo) miss(tag uint32) {
 	// Add new entry at the end
 	f.order = append(f.order, tag)
 }
+
+// describe gives the tags from the oldest one
+func (f *fifo) describe() []policyLine {
+	lines := make([]policyLine, len(f.order))
+	for i, tag := range f.order {
+		lines[i] = policyLine{tag: tag}
+	}
+	return lines
+}
+
+// remove forgets an invalidated tag
+func (f *fifo) remove(tag uint32) {
+	for i, t := range f.order {
+		if t == tag {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			return
+		}
+	}
+}
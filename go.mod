@@ -1,5 +1,19 @@
 module github.com/ag0st/gimc
 
-go 1.16
+go 1.21
 
-require github.com/ag0st/bst v0.0.0-20220412222951-523e62820e13
+require (
+	github.com/ag0st/bst v0.0.0-20220412222951-523e62820e13
+	github.com/prometheus/client_golang v1.19.1
+)
+
+require (
+	github.com/ag0st/binarytree v0.0.0-20220412222724-22db34257cac // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
@@ -0,0 +1,209 @@
+package gimc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// heatmap counts the accesses and evictions of each set over windows of accesses
+type heatmap struct {
+	window  uint64       // accesses by window
+	keep    int          // number of complete windows kept
+	total   uint64       // accesses since the creation of the cache
+	current HeatWindow   // window being filled
+	windows []HeatWindow // complete windows, the oldest first
+}
+
+// WithHeatmap counts the accesses and evictions of each set by window of this number of accesses,
+// keeping the last keep windows (see Heatmap and HotSets). Sets of regions are counted with the set of the same index.
+// Each window records the time of its first access and of the first access of the next window.
+func WithHeatmap(window uint64, keep int) Option {
+	return func(c *Cache) error {
+		if window == 0 || keep <= 0 {
+			return errors.New("the window and the number of windows kept must be positive")
+		}
+		c.heatmap = &heatmap{window: window, keep: keep}
+		c.heatmap.current = c.heatmap.newWindow(len(c.sets))
+		return nil
+	}
+}
+
+func (h *heatmap) newWindow(sets int) HeatWindow {
+	return HeatWindow{Start: h.total, Accesses: make([]uint64, sets), Evictions: make([]uint64, sets)}
+}
+
+// access counts an access to the set, it starts a new window when the current one is complete
+func (h *heatmap) access(index uint32) {
+	if h.total-h.current.Start == h.window {
+		now := time.Now()
+		h.current.End = now
+		if len(h.windows) == h.keep {
+			h.windows = append(h.windows[:0], h.windows[1:]...)
+		}
+		h.windows = append(h.windows, h.current)
+		h.current = h.newWindow(len(h.current.Accesses))
+		h.current.Begin = now
+	} else if h.total == h.current.Start {
+		h.current.Begin = time.Now() // first access to the cache
+	}
+	h.current.Accesses[index]++
+	h.total++
+}
+
+func (h *heatmap) evict(index uint32) {
+	h.current.Evictions[index]++
+}
+
+// HeatWindow is the number of accesses and evictions of each set, by index, during a window
+type HeatWindow struct {
+	Start     uint64    `json:"start"` // number of accesses to the cache before the window
+	Begin     time.Time `json:"begin"` // time of the first access of the window
+	End       time.Time `json:"end"`   // time of the first access of the next window, or of the call to Heatmap
+	Accesses  []uint64  `json:"accesses"`
+	Evictions []uint64  `json:"evictions"`
+}
+
+// Heatmap is the activity of the sets over the last windows, the oldest first
+type Heatmap struct {
+	Window  uint64       `json:"window"` // accesses by window
+	Windows []HeatWindow `json:"windows"`
+}
+
+// Heatmap gives the activity of the sets over the windows kept, the last window being the current one,
+// possibly incomplete. It is empty without WithHeatmap. It can be encoded in JSON to be plotted.
+func (c *Cache) Heatmap() Heatmap {
+	if c.heatmap == nil {
+		return Heatmap{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := Heatmap{Window: c.heatmap.window}
+	add := func(w HeatWindow) {
+		h.Windows = append(h.Windows, HeatWindow{
+			Start:     w.Start,
+			Begin:     w.Begin,
+			End:       w.End,
+			Accesses:  append([]uint64(nil), w.Accesses...),
+			Evictions: append([]uint64(nil), w.Evictions...),
+		})
+	}
+	for _, w := range c.heatmap.windows {
+		add(w)
+	}
+	if c.heatmap.current.Start < c.heatmap.total { // the current window is not empty
+		add(c.heatmap.current)
+		h.Windows[len(h.Windows)-1].End = time.Now()
+	}
+	return h
+}
+
+// WriteCSV writes a row by window and set: window, start, begin, end, set, accesses, evictions.
+// The times are in RFC 3339 with nanoseconds.
+func (h Heatmap) WriteCSV(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "window,start,begin,end,set,accesses,evictions"); err != nil {
+		return err
+	}
+	for i, window := range h.Windows {
+		begin, end := window.Begin.Format(time.RFC3339Nano), window.End.Format(time.RFC3339Nano)
+		for set := range window.Accesses {
+			_, err := fmt.Fprintf(
+				w, "%d,%d,%s,%s,%d,%d,%d\n", i, window.Start, begin, end, set, window.Accesses[set], window.Evictions[set],
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// heatShades are the characters of the ASCII heatmap, from the coldest to the hottest
+const heatShades = " .:-=+*#%@"
+
+// WriteASCII draws the accesses, or the evictions, with a column by window and a line by group of sets,
+// the sets being grouped in at most rows lines. The hottest cell is drawn with '@'.
+func (h Heatmap) WriteASCII(w io.Writer, rows int, evictions bool) error {
+	if len(h.Windows) == 0 {
+		return nil
+	}
+	sets := len(h.Windows[0].Accesses)
+	if rows <= 0 || rows > sets {
+		rows = sets
+	}
+	group := (sets + rows - 1) / rows
+	cells := make([][]uint64, (sets+group-1)/group)
+	max := uint64(0)
+	for r := range cells {
+		cells[r] = make([]uint64, len(h.Windows))
+		for c, window := range h.Windows {
+			counts := window.Accesses
+			if evictions {
+				counts = window.Evictions
+			}
+			for set := r * group; set < (r+1)*group && set < sets; set++ {
+				cells[r][c] += counts[set]
+			}
+			if cells[r][c] > max {
+				max = cells[r][c]
+			}
+		}
+	}
+	var b strings.Builder
+	for r, row := range cells {
+		fmt.Fprintf(&b, "%5d |", r*group)
+		for _, count := range row {
+			shade := 0
+			if max > 0 {
+				shade = int(count * uint64(len(heatShades)-1) / max)
+			}
+			b.WriteByte(heatShades[shade])
+		}
+		b.WriteString("|\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// HotSet is the activity of a set over the windows of the heatmap
+type HotSet struct {
+	Index     uint32  `json:"index"`
+	Accesses  uint64  `json:"accesses"`
+	Evictions uint64  `json:"evictions"`
+	Load      float64 `json:"load"` // accesses over the mean accesses of the sets, 1 for a uniform load
+}
+
+// HotSets gives the n sets the most accessed over the windows of the heatmap, the hottest first.
+// Sets accessed many times the mean load call for another indexing of the addresses or for more ways.
+func (c *Cache) HotSets(n int) []HotSet {
+	h := c.Heatmap()
+	if len(h.Windows) == 0 || n <= 0 {
+		return nil
+	}
+	hot := make([]HotSet, len(h.Windows[0].Accesses))
+	total := uint64(0)
+	for i := range hot {
+		hot[i].Index = uint32(i)
+		for _, w := range h.Windows {
+			hot[i].Accesses += w.Accesses[i]
+			hot[i].Evictions += w.Evictions[i]
+		}
+		total += hot[i].Accesses
+	}
+	for i := range hot {
+		hot[i].Load = float64(hot[i].Accesses) * float64(len(hot)) / float64(total)
+	}
+	sort.SliceStable(hot, func(i, j int) bool {
+		if hot[i].Accesses != hot[j].Accesses {
+			return hot[i].Accesses > hot[j].Accesses
+		}
+		return hot[i].Evictions > hot[j].Evictions
+	})
+	if n < len(hot) {
+		hot = hot[:n]
+	}
+	return hot
+}
@@ -0,0 +1,72 @@
+package gimc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeatmap(t *testing.T) {
+	src := newMemSource(1 << 20)
+	// 64 sets of 1 way, blocks of 64 bytes: addresses multiple of 4096, as the hash prefixes of toAddress,
+	// always fall in set 0
+	cache, err := CreateCache(64, 64, 8, 1, src, LRU, WithHeatmap(10, 3))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	for i := uint32(0); i < 45; i++ {
+		if i%3 == 0 {
+			cache.Get(i * 64) // spread on the sets
+		} else {
+			cache.Get(i % 8 * 4096)
+		}
+	}
+	h := cache.Heatmap()
+	// 4 complete windows and 5 accesses in the current one, the first window is dropped
+	if h.Window != 10 || len(h.Windows) != 4 || h.Windows[0].Start != 10 || h.Windows[3].Start != 40 {
+		t.Fatal(fmt.Sprintf("Wrong windows: %+v", h))
+	}
+	for i, w := range h.Windows {
+		if w.Begin.IsZero() || w.End.Before(w.Begin) || (i > 0 && !h.Windows[i-1].End.Equal(w.Begin)) {
+			t.Fatal(fmt.Sprintf("Wrong times of the window %d: %v to %v", i, w.Begin, w.End))
+		}
+	}
+	accesses := uint64(0)
+	for _, w := range h.Windows {
+		for _, n := range w.Accesses {
+			accesses += n
+		}
+	}
+	if accesses != 35 {
+		t.Fatal(fmt.Sprintf("Wrong number of accesses: %d", accesses))
+	}
+
+	hot := cache.HotSets(2)
+	if len(hot) != 2 || hot[0].Index != 0 || hot[0].Load < 10 || hot[0].Evictions == 0 {
+		t.Fatal(fmt.Sprintf("Set 0 must be the hottest: %+v", hot))
+	}
+
+	if hot := cache.HotSets(-1); hot != nil {
+		t.Fatal(fmt.Sprintf("No set must be given for a negative number: %+v", hot))
+	}
+
+	var csv, ascii bytes.Buffer
+	if err := h.WriteCSV(&csv); err != nil || strings.Count(csv.String(), "\n") != 1+4*64 ||
+		!strings.HasPrefix(csv.String(), "window,start,begin,end,set,accesses,evictions\n0,10,"+h.Windows[0].Begin.Format(time.RFC3339Nano)) {
+		t.Fatal(fmt.Sprintf("Wrong CSV (%v):\n%s", err, csv.String()))
+	}
+	if err := h.WriteASCII(&ascii, 8, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(ascii.String(), "\n"), "\n")
+	if len(lines) != 8 || !strings.HasPrefix(lines[0], "    0 |@") || lines[7] != "   56 |    |" {
+		t.Fatal(fmt.Sprintf("Wrong ASCII heatmap:\n%s", ascii.String()))
+	}
+	var decoded Heatmap
+	if data, err := json.Marshal(h); err != nil || json.Unmarshal(data, &decoded) != nil || decoded.Windows[2].Start != 30 || !decoded.Windows[2].Begin.Equal(h.Windows[2].Begin) {
+		t.Fatal(fmt.Sprintf("Cannot encode the heatmap in JSON: %v", err))
+	}
+}
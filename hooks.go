@@ -0,0 +1,63 @@
+package gimc
+
+// Hooks are callbacks called on the events of the cache, nil callbacks are ignored.
+//
+// The callbacks are called synchronously by Get and Read, in the goroutine calling it, while the cache is locked.
+// They must therefore be fast and must not call the methods locking the same cache, which would deadlock:
+// Get, Read, Invalidate, Flush, Snapshot, SnapshotState, Heatmap, HotSets, HotBlocks and RecentEvictions.
+// Stats, GetCounters, GetMissClasses and ShadowStats do not lock the cache and can be called.
+// The data given to OnEvict are only valid during the call and must be copied to be kept.
+type Hooks struct {
+	OnHit   func(address uint32)                          // the data at this address were in the cache
+	OnMiss  func(address uint32)                          // the data at this address were not in the cache
+	OnFill  func(address uint32)                          // the block at this address has been put in the cache
+	OnEvict func(address uint32, data []byte, dirty bool) // the block at this address has been removed from the cache
+}
+
+// WithHooks registers callbacks on the events of the cache. It can be given several times,
+// the callbacks are then called in the order of the options.
+func WithHooks(hooks Hooks) Option {
+	return func(c *Cache) error {
+		c.hooks = append(c.hooks, hooks)
+		return nil
+	}
+}
+
+func (c *Cache) onHit(address uint32) {
+	for _, h := range c.hooks {
+		if h.OnHit != nil {
+			h.OnHit(address)
+		}
+	}
+}
+
+func (c *Cache) onMiss(address uint32) {
+	for _, h := range c.hooks {
+		if h.OnMiss != nil {
+			h.OnMiss(address)
+		}
+	}
+}
+
+func (c *Cache) onFill(address uint32) {
+	for _, h := range c.hooks {
+		if h.OnFill != nil {
+			h.OnFill(address)
+		}
+	}
+}
+
+// onEvict gives the evicted line (edition bits followed by the data, nil if only tags are kept) to the hooks
+func (c *Cache) onEvict(address uint32, line []byte) {
+	var data []byte
+	dirty := false
+	if line != nil {
+		data = line[1:]
+		dirty = line[0]&MODIFIED != 0
+	}
+	for _, h := range c.hooks {
+		if h.OnEvict != nil {
+			h.OnEvict(address, data, dirty)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package gimc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	src := newMemSource(1 << 16)
+	var events []string
+	hooks := Hooks{
+		OnHit:  func(address uint32) { events = append(events, fmt.Sprintf("hit %d", address)) },
+		OnMiss: func(address uint32) { events = append(events, fmt.Sprintf("miss %d", address)) },
+		OnFill: func(address uint32) { events = append(events, fmt.Sprintf("fill %d", address)) },
+		OnEvict: func(address uint32, data []byte, dirty bool) {
+			if bytes.Compare(data, sourceBytes(address, 64)) != 0 || dirty {
+				t.Fatal("Wrong evicted data")
+			}
+			events = append(events, fmt.Sprintf("evict %d", address))
+		},
+	}
+	evictions := 0
+	var cache *Cache
+	cache, err := CreateCache(
+		1, 64, 8, 1, src, LRU, WithHooks(hooks), WithHooks(
+			Hooks{
+				OnEvict: func(address uint32, data []byte, dirty bool) {
+					// Stats does not lock the cache
+					evictions = int(cache.Stats().Evictions)
+				},
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	cache.Get(0)
+	cache.Get(8)
+	cache.Get(72)
+	expected := []string{"miss 0", "fill 0", "hit 8", "miss 72", "evict 0", "fill 64"}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Fatal(fmt.Sprintf("Wrong events %v, expected %v", events, expected))
+	}
+	if evictions != 1 {
+		t.Fatal("Every hook must be called")
+	}
+}
@@ -0,0 +1,145 @@
+// Package inspect serves the live state of a gimc cache over HTTP, as HTML for humans and JSON for tools.
+//
+// The handler is meant to be mounted under a prefix removed with http.StripPrefix:
+//
+//	mux.Handle("/debug/gimc/", http.StripPrefix("/debug/gimc", inspect.NewHandler(cache, token)))
+//
+// It serves:
+//   - GET / the statistics, the occupancy and policy state of each set, the hottest blocks and the recent evictions,
+//     as HTML, or as JSON with ?format=json or an Accept header asking for application/json,
+//   - POST /invalidate?address=0x1234 invalidates the block of the address,
+//   - POST /flush removes all the blocks.
+//
+// The hottest blocks and the recent evictions need the cache to be created with gimc.WithLineTracking.
+// The POST requests need the header "Authorization: Bearer <token>", they are refused if the token is empty.
+package inspect
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ag0st/gimc"
+)
+
+// defaultHotBlocks is the number of hottest blocks given without the n parameter
+const defaultHotBlocks = 20
+
+//go:embed inspect.html
+var page string
+
+var pageTemplate = template.Must(template.New("inspect").Funcs(template.FuncMap{
+	"hex": func(address uint32) string { return fmt.Sprintf("%#x", address) },
+}).Parse(page))
+
+// Report is the state of the cache served by the handler
+type Report struct {
+	Stats     gimc.Stats         `json:"stats"`
+	Lines     int                `json:"lines"`
+	Capacity  int                `json:"capacity"`
+	Sets      []gimc.SetSnapshot `json:"sets"` // sets of the cache then of the regions, without the data of the lines
+	HotBlocks []gimc.HotBlock    `json:"hot_blocks"`
+	Evictions []gimc.Eviction    `json:"recent_evictions"`
+}
+
+// Handler serves the state of a cache, see the documentation of the package
+type Handler struct {
+	cache *gimc.Cache
+	token []byte
+}
+
+// NewHandler creates a handler for the cache. token authenticates the POST requests, they are refused if it is empty.
+func NewHandler(cache *gimc.Cache, token string) *Handler {
+	return &Handler{cache: cache, token: []byte(token)}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.serveReport(w, r)
+	case "/invalidate", "/flush":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !h.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.serveAction(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorized tells if the request has the bearer token, compared in constant time
+func (h *Handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && len(h.token) > 0 && subtle.ConstantTimeCompare([]byte(token), h.token) == 1
+}
+
+func (h *Handler) serveReport(w http.ResponseWriter, r *http.Request) {
+	n := defaultHotBlocks
+	if s := r.URL.Query().Get("n"); s != "" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil || n < 0 {
+			http.Error(w, "n must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+	report := h.report(n)
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = pageTemplate.Execute(w, report)
+}
+
+// report gives the state of the cache with the n hottest blocks
+func (h *Handler) report(n int) Report {
+	snapshot := h.cache.SnapshotState()
+	report := Report{
+		Stats:     h.cache.Stats(),
+		Lines:     snapshot.Lines,
+		Capacity:  snapshot.Capacity,
+		Sets:      snapshot.Sets,
+		HotBlocks: h.cache.HotBlocks(n),
+		Evictions: h.cache.RecentEvictions(),
+	}
+	for _, region := range snapshot.Regions {
+		report.Sets = append(report.Sets, region.Sets...)
+	}
+	return report
+}
+
+func (h *Handler) serveAction(w http.ResponseWriter, r *http.Request) {
+	if strings.TrimSuffix(r.URL.Path, "/") == "/flush" {
+		h.cache.Flush()
+		fmt.Fprintln(w, "flushed")
+		return
+	}
+	address, err := strconv.ParseUint(r.URL.Query().Get("address"), 0, 32)
+	if err != nil {
+		http.Error(w, "address must be a 32 bits address, in decimal or 0x hexadecimal", http.StatusBadRequest)
+		return
+	}
+	if h.cache.Invalidate(uint32(address)) {
+		fmt.Fprintln(w, "invalidated")
+	} else {
+		fmt.Fprintln(w, "not cached")
+	}
+}
@@ -0,0 +1,97 @@
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ag0st/gimc"
+)
+
+// newServer serves the handler of a cache of 2 sets of 2 ways holding the blocks 0 (hit twice), 64 and 128
+func newServer(t *testing.T) (*httptest.Server, *gimc.Cache) {
+	cache, err := gimc.CreateCache(2, 64, 8, 2, gimc.NewMemoryDatasource(make([]byte, 4096)), gimc.LRU, gimc.WithLineTracking(10))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	for _, address := range []uint32{0, 64, 128, 0, 8} {
+		cache.Get(address)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/gimc/", http.StripPrefix("/debug/gimc", NewHandler(cache, "secret")))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, cache
+}
+
+func request(t *testing.T, method, url, token string) (int, string) {
+	req, _ := http.NewRequest(method, url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot request %s: %s", url, err))
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestReport(t *testing.T) {
+	server, _ := newServer(t)
+	status, body := request(t, http.MethodGet, server.URL+"/debug/gimc/?format=json&n=1", "")
+	var report Report
+	if err := json.Unmarshal([]byte(body), &report); status != http.StatusOK || err != nil {
+		t.Fatal(fmt.Sprintf("Cannot get the report (%d, %v): %s", status, err, body))
+	}
+	if report.Stats.Hits != 2 || report.Lines != 3 || report.Capacity != 4 || len(report.Sets) != 2 {
+		t.Fatal(fmt.Sprintf("Wrong report: %+v", report))
+	}
+	if len(report.HotBlocks) != 1 || report.HotBlocks[0].Address != 0 || report.HotBlocks[0].Hits != 2 {
+		t.Fatal(fmt.Sprintf("Wrong hot blocks: %+v", report.HotBlocks))
+	}
+	if line := report.Sets[0].Lines[0]; line.Address != 128 || line.Data != nil {
+		t.Fatal(fmt.Sprintf("Wrong state of set 0: %+v", report.Sets[0]))
+	}
+
+	status, body = request(t, http.MethodGet, server.URL+"/debug/gimc/", "")
+	if status != http.StatusOK || !strings.Contains(body, `<td>0</td><td>LRU</td><td>2/2</td><td class="lines">0x80:0 0x0:0 </td>`) {
+		t.Fatal(fmt.Sprintf("Wrong page (%d):\n%s", status, body))
+	}
+	if status, _ := request(t, http.MethodPost, server.URL+"/debug/gimc/", "secret"); status != http.StatusMethodNotAllowed {
+		t.Fatal("The report is read-only")
+	}
+}
+
+func TestActions(t *testing.T) {
+	server, cache := newServer(t)
+	for _, token := range []string{"", "wrong"} {
+		if status, _ := request(t, http.MethodPost, server.URL+"/debug/gimc/flush", token); status != http.StatusUnauthorized {
+			t.Fatal(fmt.Sprintf("Must refuse the token %q", token))
+		}
+	}
+	if status, _ := request(t, http.MethodGet, server.URL+"/debug/gimc/flush", "secret"); status != http.StatusMethodNotAllowed {
+		t.Fatal("Actions need POST")
+	}
+	status, body := request(t, http.MethodPost, server.URL+"/debug/gimc/invalidate?address=0x40", "secret")
+	if status != http.StatusOK || body != "invalidated\n" || cache.Snapshot().Lines != 2 {
+		t.Fatal(fmt.Sprintf("Cannot invalidate (%d): %s", status, body))
+	}
+	if status, _ := request(t, http.MethodPost, server.URL+"/debug/gimc/invalidate?address=x", "secret"); status != http.StatusBadRequest {
+		t.Fatal("Must refuse a wrong address")
+	}
+	if status, _ := request(t, http.MethodPost, server.URL+"/debug/gimc/flush", "secret"); status != http.StatusOK || cache.Snapshot().Lines != 0 {
+		t.Fatal("Cannot flush")
+	}
+
+	empty := httptest.NewServer(NewHandler(cache, ""))
+	defer empty.Close()
+	if status, _ := request(t, http.MethodPost, empty.URL+"/flush", ""); status != http.StatusUnauthorized {
+		t.Fatal("Actions must be refused without token")
+	}
+}
@@ -0,0 +1,160 @@
+package gimc
+
+import (
+	"io"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// latencySubBits is the number of bits of precision of the latency buckets: each power of two is split
+// in 2^latencySubBits buckets, an error of at most 12.5%
+const latencySubBits = 3
+
+// latencyBuckets is the number of buckets needed for any duration in nanoseconds
+const latencyBuckets = (64-latencySubBits)<<latencySubBits + 1<<latencySubBits
+
+// latencyBucket gives the bucket of a duration in nanoseconds: the values lower than 2^(latencySubBits+1) have their
+// own bucket, the bigger ones are grouped by their latencySubBits+1 most significant bits (log-linear, as HDR histograms)
+func latencyBucket(ns uint64) int {
+	if ns < 2<<latencySubBits {
+		return int(ns)
+	}
+	shift := bits.Len64(ns) - latencySubBits - 1
+	return shift<<latencySubBits + int(ns>>shift)
+}
+
+// latencyUpperBound gives the greatest duration in nanoseconds of the bucket
+func latencyUpperBound(bucket int) uint64 {
+	if bucket < 2<<latencySubBits {
+		return uint64(bucket)
+	}
+	shift := bucket>>latencySubBits - 1
+	mantissa := uint64(bucket&(1<<latencySubBits-1) + 1<<latencySubBits)
+	return (mantissa+1)<<shift - 1
+}
+
+// opCounters are the statistics of an operation of a datasource, updated atomically
+type opCounters struct {
+	calls, bytes, errors uint64
+	inFlight             int64
+	totalNs              uint64
+	latency              [latencyBuckets]uint64 // calls by bucket of latency
+}
+
+// record records a call of the operation started at start
+func (o *opCounters) record(start time.Time, n int, err error) {
+	ns := uint64(time.Since(start).Nanoseconds())
+	atomic.AddInt64(&o.inFlight, -1)
+	atomic.AddUint64(&o.calls, 1)
+	atomic.AddUint64(&o.bytes, uint64(n))
+	if err != nil && err != io.EOF {
+		atomic.AddUint64(&o.errors, 1)
+	}
+	atomic.AddUint64(&o.totalNs, ns)
+	atomic.AddUint64(&o.latency[latencyBucket(ns)], 1)
+}
+
+func (o *opCounters) snapshot() OpStats {
+	s := OpStats{
+		Calls:    atomic.LoadUint64(&o.calls),
+		Bytes:    atomic.LoadUint64(&o.bytes),
+		Errors:   atomic.LoadUint64(&o.errors),
+		InFlight: atomic.LoadInt64(&o.inFlight),
+		Total:    time.Duration(atomic.LoadUint64(&o.totalNs)),
+	}
+	for i := range o.latency {
+		if n := atomic.LoadUint64(&o.latency[i]); n > 0 {
+			s.Latency = append(s.Latency, LatencyBucket{UpperBound: time.Duration(latencyUpperBound(i)), Count: n})
+		}
+	}
+	return s
+}
+
+// InstrumentedDatasource records the latency, the bytes and the errors of the reads and writes of a datasource.
+// Given to CreateCache, its statistics are part of the statistics of the cache (see Stats.Source) and the cache
+// records the time it spends handling each miss, reading the source included.
+// It is safe for concurrent use if the datasource is.
+type InstrumentedDatasource struct {
+	reads, writes, misses opCounters // first for the alignment of the atomic counters
+	source                Datasource
+}
+
+// NewInstrumentedDatasource wraps the datasource
+func NewInstrumentedDatasource(source Datasource) *InstrumentedDatasource {
+	return &InstrumentedDatasource{source: source}
+}
+
+func (d *InstrumentedDatasource) ReadAt(p []byte, off int64) (n int, err error) {
+	atomic.AddInt64(&d.reads.inFlight, 1)
+	start := time.Now()
+	n, err = d.source.ReadAt(p, off)
+	d.reads.record(start, n, err)
+	return n, err
+}
+
+func (d *InstrumentedDatasource) WriteAt(p []byte, off int64) (n int, err error) {
+	atomic.AddInt64(&d.writes.inFlight, 1)
+	start := time.Now()
+	n, err = d.source.WriteAt(p, off)
+	d.writes.record(start, n, err)
+	return n, err
+}
+
+func (d *InstrumentedDatasource) Open() error  { return d.source.Open() }
+func (d *InstrumentedDatasource) Close() error { return d.source.Close() }
+
+// Stats gives a snapshot of the statistics of the reads, the writes and the misses of the cache
+func (d *InstrumentedDatasource) Stats() SourceStats {
+	return SourceStats{Reads: d.reads.snapshot(), Writes: d.writes.snapshot(), Misses: d.misses.snapshot()}
+}
+
+// SourceStats are the statistics of an InstrumentedDatasource.
+// Misses.Total - Reads.Total is the time the misses spent in the cache and not waiting on the source.
+type SourceStats struct {
+	Reads  OpStats `json:"reads"`
+	Writes OpStats `json:"writes"`
+	Misses OpStats `json:"misses"` // misses handled by the cache, from the lookup to the insertion of the block
+}
+
+// OpStats are the statistics of the calls of ReadAt or WriteAt, or of the misses of the cache.
+// io.EOF is not counted as an error, the cache reading blocks past the end of the source.
+type OpStats struct {
+	Calls    uint64          `json:"calls"`
+	Bytes    uint64          `json:"bytes"`
+	Errors   uint64          `json:"errors"`
+	InFlight int64           `json:"in_flight"` // calls not returned yet
+	Total    time.Duration   `json:"total_ns"`  // time spent in the calls
+	Latency  []LatencyBucket `json:"latency"`   // non-empty buckets, by increasing latency
+}
+
+// LatencyBucket is the number of calls whose latency is at most UpperBound, and above the bound of the previous bucket
+type LatencyBucket struct {
+	UpperBound time.Duration `json:"upper_bound_ns"`
+	Count      uint64        `json:"count"`
+}
+
+// Mean gives the mean latency of the calls
+func (s OpStats) Mean() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// Quantile gives the upper bound of the latency of the fraction q of the calls, for instance 0.99 for the 99th percentile
+func (s OpStats) Quantile(q float64) time.Duration {
+	count := uint64(0)
+	for _, b := range s.Latency {
+		count += b.Count
+	}
+	rank := uint64(q * float64(count))
+	seen := uint64(0)
+	for _, b := range s.Latency {
+		seen += b.Count
+		if seen > rank || seen == count {
+			return b.UpperBound
+		}
+	}
+	return 0
+}
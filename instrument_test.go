@@ -0,0 +1,72 @@
+package gimc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// slowSource is a MemoryDatasource taking some time to read, and failing to write
+type slowSource struct {
+	*MemoryDatasource
+	delay time.Duration
+}
+
+func (s slowSource) ReadAt(p []byte, off int64) (n int, err error) {
+	time.Sleep(s.delay)
+	return s.MemoryDatasource.ReadAt(p, off)
+}
+
+func (s slowSource) WriteAt(p []byte, off int64) (n int, err error) {
+	return 0, errors.New("read only")
+}
+
+func TestLatencyBuckets(t *testing.T) {
+	previous := -1
+	for _, ns := range []uint64{0, 1, 15, 16, 17, 18, 1000, 1 << 40, 1<<64 - 1} {
+		bucket := latencyBucket(ns)
+		if bucket < previous || bucket >= latencyBuckets || latencyUpperBound(bucket) < ns {
+			t.Fatal(fmt.Sprintf("Wrong bucket %d for %d ns, upper bound %d", bucket, ns, latencyUpperBound(bucket)))
+		}
+		if ns >= 16 && float64(latencyUpperBound(bucket)-ns) > float64(ns)/8 {
+			t.Fatal(fmt.Sprintf("Bucket %d too wide for %d ns: %d", bucket, ns, latencyUpperBound(bucket)))
+		}
+		previous = bucket
+	}
+}
+
+func TestInstrumentedDatasource(t *testing.T) {
+	source := NewInstrumentedDatasource(slowSource{MemoryDatasource: newMemSource(1024), delay: time.Millisecond})
+	cache, err := CreateCache(4, 64, 8, 2, source, LRU)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	for _, address := range []uint32{0, 64, 0, 2048} { // the last block is past the end of the source
+		cache.Get(address)
+	}
+	if _, err := source.WriteAt([]byte{1}, 0); err == nil {
+		t.Fatal("The error of the source must be returned")
+	}
+
+	stats := cache.Stats().Source
+	if stats == nil {
+		t.Fatal("Stats must give the statistics of an instrumented source")
+	}
+	reads := stats.Reads
+	if reads.Calls != 3 || reads.Bytes != 128 || reads.Errors != 0 || reads.InFlight != 0 {
+		t.Fatal(fmt.Sprintf("Wrong reads: %+v", reads))
+	}
+	if reads.Mean() < time.Millisecond || reads.Quantile(0.5) < time.Millisecond || reads.Quantile(1) < reads.Quantile(0.5) {
+		t.Fatal(fmt.Sprintf("Wrong latency: mean %s, median %s", reads.Mean(), reads.Quantile(0.5)))
+	}
+	if stats.Misses.Calls != 3 || stats.Misses.Total < reads.Total {
+		t.Fatal(fmt.Sprintf("Wrong misses: %+v", stats.Misses))
+	}
+	if stats.Writes.Calls != 1 || stats.Writes.Errors != 1 {
+		t.Fatal(fmt.Sprintf("Wrong writes: %+v", stats.Writes))
+	}
+	if other, _ := CreateCache(4, 64, 8, 2, newMemSource(1024), LRU); other.Stats().Source != nil {
+		t.Fatal("Only an instrumented source has statistics")
+	}
+}
@@ -0,0 +1,159 @@
+package gimc
+
+// states of the tags tracked by lirs, stored in the value of the nodes of the stack
+const (
+	lirsLIR         = iota // resident, low inter-reference recency (hot)
+	lirsHIR                // resident, high inter-reference recency (cold)
+	lirsNonResident        // not resident anymore, only its recency is remembered
+)
+
+// lirs is the structure used to implement and represent the LIRS (Low Inter-reference Recency Set) replacement policy.
+// The stack orders the tags by recency (back is the most recent) and always has a LIR tag at the bottom (front).
+// The queue holds the resident HIR tags, which are the only candidates for replacement.
+// A HIR tag referenced again while still in the stack has a lower inter-reference recency than the oldest LIR tag,
+// so it becomes LIR and the oldest LIR tag becomes HIR. Loops slightly larger than the set keep most of their tags.
+type lirs struct {
+	stack          *tagList // LIR, resident HIR and non-resident HIR tags ordered by recency
+	queue          *tagList // resident HIR tags, front is the next to replace
+	lirCount       int      // number of LIR tags
+	maxLIR         int      // maximum number of LIR tags
+	nonResident    int      // number of non-resident tags in the stack
+	maxNonResident int      // maximum number of non-resident tags remembered
+}
+
+// newLIRS creates a LIRS policy for a set of maxWays ways. 1% of the ways (at least one) are kept for HIR tags.
+func newLIRS(maxWays uint16) *lirs {
+	return &lirs{
+		stack:          newTagList(),
+		queue:          newTagList(),
+		maxLIR:         int(maxWays) - maxInt(1, int(maxWays)/100),
+		maxNonResident: int(maxWays),
+	}
+}
+
+func (l *lirs) toReplace() uint32 {
+	n := l.queue.popFront()
+	if n == nil {
+		// only LIR tags are resident, replace the oldest one
+		n = l.stack.popFront()
+		l.lirCount--
+		l.prune()
+		return n.tag
+	}
+	if sn := l.stack.get(n.tag); sn != nil {
+		sn.val = lirsNonResident
+		l.nonResident++
+		if l.nonResident > l.maxNonResident {
+			l.forgetNonResident()
+		}
+	}
+	return n.tag
+}
+
+func (l *lirs) victim() uint32 {
+	if n := l.queue.front(); n != nil {
+		return n.tag
+	}
+	return l.stack.front().tag
+}
+
+func (l *lirs) hit(tag uint32) {
+	sn := l.stack.get(tag)
+	switch {
+	case sn != nil && sn.val == lirsLIR:
+		l.stack.moveToBack(sn)
+		l.prune()
+	case sn != nil:
+		// resident HIR still in the stack, it becomes LIR
+		sn.val = lirsLIR
+		l.stack.moveToBack(sn)
+		l.queue.remove(tag)
+		l.lirCount++
+		l.demote()
+	default:
+		// resident HIR not in the stack anymore, stays HIR
+		l.stack.pushBack(tag).val = lirsHIR
+		l.queue.moveToBack(l.queue.get(tag))
+	}
+}
+
+func (l *lirs) miss(tag uint32) {
+	sn := l.stack.get(tag)
+	switch {
+	case l.lirCount < l.maxLIR:
+		// the set is not full yet, everything is LIR
+		if sn != nil {
+			l.stack.remove(tag)
+			l.nonResident--
+		}
+		l.stack.pushBack(tag).val = lirsLIR
+		l.lirCount++
+	case sn != nil:
+		// non-resident HIR still in the stack, it becomes LIR
+		l.nonResident--
+		sn.val = lirsLIR
+		l.stack.moveToBack(sn)
+		l.lirCount++
+		l.demote()
+	default:
+		l.stack.pushBack(tag).val = lirsHIR
+		l.queue.pushBack(tag)
+	}
+}
+
+// demote turns the oldest LIR tags into resident HIR tags until there are not more than maxLIR of them
+func (l *lirs) demote() {
+	for l.lirCount > l.maxLIR {
+		l.prune()
+		n := l.stack.popFront()
+		l.lirCount--
+		l.queue.pushBack(n.tag)
+	}
+	l.prune()
+}
+
+// prune removes the HIR tags at the bottom of the stack so that the bottom is a LIR tag
+func (l *lirs) prune() {
+	for n := l.stack.front(); n != nil && n.val != lirsLIR; n = l.stack.front() {
+		l.stack.popFront()
+		if n.val == lirsNonResident {
+			l.nonResident--
+		}
+	}
+}
+
+// forgetNonResident removes the oldest non-resident tag of the stack to bound its size
+func (l *lirs) forgetNonResident() {
+	for n := l.stack.front(); n != nil; n = l.stack.next(n) {
+		if n.val == lirsNonResident {
+			l.stack.remove(n.tag)
+			l.nonResident--
+			return
+		}
+	}
+}
+
+// describe gives the resident HIR tags from the next to be replaced, then the LIR tags from the least recent one
+func (l *lirs) describe() []policyLine {
+	lines := describeList(l.queue, "hir", nil)
+	for n := l.stack.front(); n != nil; n = l.stack.next(n) {
+		if n.val == lirsLIR {
+			lines = append(lines, policyLine{tag: n.tag, queue: "lir"})
+		}
+	}
+	return lines
+}
+
+// remove forgets an invalidated tag, resident LIR or HIR
+func (l *lirs) remove(tag uint32) {
+	if l.queue.remove(tag) {
+		// resident HIR, also forget its recency
+		l.stack.remove(tag)
+		return
+	}
+	if sn := l.stack.get(tag); sn != nil && sn.val == lirsLIR {
+		l.stack.remove(tag)
+		l.lirCount--
+		l.prune()
+	}
+}
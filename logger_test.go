@@ -0,0 +1,85 @@
+package gimc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// brokenSource is a MemoryDatasource failing to read the addresses from broken
+type brokenSource struct {
+	*MemoryDatasource
+	broken int64
+}
+
+func (b brokenSource) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= b.broken {
+		return 0, errors.New("broken disk")
+	}
+	return b.MemoryDatasource.ReadAt(p, off)
+}
+
+func TestReadError(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	cache, err := CreateCache(4, 64, 8, 2, brokenSource{newMemSource(1024), 512}, LRU, WithLogger(logger))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	if data, err := cache.Read(512); err == nil || data != nil || !strings.Contains(err.Error(), "broken disk") {
+		t.Fatal(fmt.Sprintf("Read must give the error of the source: %v, %v", data, err))
+	}
+	if data := cache.Get(520); data != nil {
+		t.Fatal("Get must give nil when the source fails")
+	}
+	if !strings.Contains(logs.String(), "level=ERROR msg=\"cannot read the block\" address=0x208") {
+		t.Fatal(fmt.Sprintf("The error must be logged:\n%s", logs.String()))
+	}
+	if _, misses := cache.GetCounters(); misses != 2 {
+		t.Fatal("A block that cannot be read must not be cached")
+	}
+	if data, err := cache.Read(8); err != nil || data[0] != 8*7 {
+		t.Fatal(fmt.Sprintf("The other blocks must be read: %v, %v", data, err))
+	}
+}
+
+func TestInvalidGeometry(t *testing.T) {
+	for _, g := range []Geometry{{Sets: 4, Ways: 0, BlockSize: 64}, {Sets: 3, Ways: 2, BlockSize: 64}, {Sets: 4, Ways: 2, BlockSize: 48}} {
+		if _, err := CreateCache(g.Sets, g.BlockSize, 8, g.Ways, newMemSource(1024), LRU); err == nil {
+			t.Fatal(fmt.Sprintf("The geometry %+v must be refused", g))
+		}
+	}
+}
+
+func TestDebugLogs(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cache, err := CreateCache(1, 64, 8, 1, newMemSource(1024), LRU, WithLogger(logger))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	cache.Get(0)
+	cache.Get(0)
+	cache.Get(64)
+	for _, line := range []string{
+		"msg=miss tag=0 address=0x0 set=0 policy=LRU",
+		"msg=hit tag=0 address=0x0 set=0 policy=LRU",
+		"msg=evict tag=0 address=0x0 set=0 policy=LRU",
+		"msg=miss tag=1 address=0x40 set=0 policy=LRU",
+	} {
+		if !strings.Contains(logs.String(), line) {
+			t.Fatal(fmt.Sprintf("Missing %q in:\n%s", line, logs.String()))
+		}
+	}
+
+	// without the debug level, a hit costs no allocation
+	logs.Reset()
+	cache, _ = CreateCache(1, 64, 8, 1, newMemSource(1024), LRU, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	cache.Get(0)
+	if allocs := testing.AllocsPerRun(100, func() { cache.Get(0) }); allocs != 0 || logs.Len() != 0 {
+		t.Fatal(fmt.Sprintf("Debug messages must cost nothing when disabled: %v allocations, %q", allocs, logs.String()))
+	}
+}
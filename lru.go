@@ -1,60 +1,38 @@
 package gimc
 
-import (
-	"github.com/ag0st/gimc/pkg/heap"
-	"log"
-	"math"
-)
-
 // lru is the structure used to implement and represent the Least Recently Used replacement policy.
+// The tags are kept in a doubly linked list ordered by recency, hits and misses are O(1).
 type lru struct {
-	heap    *heap.Heap // Used for LRU replacement algorithm
-	lclock  uint32     // logical clock for ordering new entries in the cache
-	maxSize uint16     // maxSize is the maximum size of the heap (the maximum number of ways)
+	order *tagList // front is the least recently used tag
+}
+
+func newLRU() *lru {
+	return &lru{order: newTagList()}
 }
 
 func (l *lru) toReplace() uint32 {
-	min := l.heap.RemoveMin()
-	return min[1]
+	return l.order.popFront().tag
+}
+
+func (l *lru) victim() uint32 {
+	return l.order.front().tag
 }
 
+// Must be called when hit, part of the replacement algorithm
 func (l *lru) hit(tag uint32) {
-	data := [2]uint32{
-		l.lclock,
-		tag,
-	}
-	l.heap.Update(data)
-	l.lclock++
-	l.maybeRebuild()
+	l.order.moveToBack(l.order.get(tag))
 }
 
+// Must be called when miss, part of the replacement algorithm
 func (l *lru) miss(tag uint32) {
-	data := [2]uint32{
-		l.lclock,
-		tag,
-	}
-	err := l.heap.Add(data)
-	if err != nil {
-		log.Fatalln("CACHE SET: Cannot add new entry in the heap when miss happened")
-	}
-	l.lclock++
-	l.maybeRebuild()
-}
-
-// Must be called when miss or hit, part of the replacement algorithm
-func (l *lru) maybeRebuild() {
-	if l.lclock == math.MaxInt32 {
-		// need to rebuild the heap for consistency
-		l.lclock = 0
-		newHeap := heap.NewHeap(int(l.maxSize))
-		for i := 0; i < l.heap.Size(); i++ {
-			data := l.heap.RemoveMin()
-			data[0] = l.lclock
-			err := newHeap.Add(data)
-			if err != nil {
-				log.Fatalln("LRU: Cannot rebuild the heap")
-			}
-			l.lclock++
-		}
-	}
+	l.order.pushBack(tag)
+}
+
+// describe gives the tags from the least recently used one
+func (l *lru) describe() []policyLine {
+	return describeList(l.order, "", nil)
+}
+
+func (l *lru) remove(tag uint32) {
+	l.order.remove(tag)
 }
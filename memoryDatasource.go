@@ -0,0 +1,78 @@
+package gimc
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// MemoryDatasource is a datasource on a byte slice, growing when written past its end.
+// It is safe for concurrent use.
+type MemoryDatasource struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+// NewMemoryDatasource creates a datasource on the data, which must not be modified afterwards. data can be nil.
+func NewMemoryDatasource(data []byte) *MemoryDatasource {
+	return &MemoryDatasource{data: data}
+}
+
+// ReadAt reads len(p) bytes at offset off. As io.ReaderAt, it returns io.EOF with the bytes available
+// if fewer than len(p) bytes are left.
+func (m *MemoryDatasource) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n = copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt writes p at offset off, the data grow if needed, the gap being filled with zeros
+func (m *MemoryDatasource) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if end := off + int64(len(p)); end > int64(len(m.data)) {
+		if end > int64(cap(m.data)) {
+			grown := make([]byte, end, 2*end)
+			copy(grown, m.data)
+			m.data = grown
+		} else {
+			old := len(m.data)
+			m.data = m.data[:end]
+			clear(m.data[old:]) // the capacity may hold old bytes
+		}
+	}
+	return copy(m.data[off:], p), nil
+}
+
+// Open does nothing, the data are always available
+func (m *MemoryDatasource) Open() error { return nil }
+
+// Close does nothing, the data are kept
+func (m *MemoryDatasource) Close() error { return nil }
+
+// Len gives the size of the data
+func (m *MemoryDatasource) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.data)
+}
+
+// Bytes gives a copy of the data
+func (m *MemoryDatasource) Bytes() []byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]byte(nil), m.data...)
+}
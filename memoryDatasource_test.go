@@ -0,0 +1,48 @@
+package gimc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestMemoryDatasource(t *testing.T) {
+	m := NewMemoryDatasource(make([]byte, 4, 16))
+	if n, err := m.WriteAt([]byte{1, 2}, 1); n != 2 || err != nil || !bytes.Equal(m.Bytes(), []byte{0, 1, 2, 0}) {
+		t.Fatal(fmt.Sprintf("Wrong write in place: %d, %v, %v", n, err, m.Bytes()))
+	}
+	// past the end, within the capacity then beyond it
+	if n, err := m.WriteAt([]byte{3}, 6); n != 1 || err != nil || !bytes.Equal(m.Bytes(), []byte{0, 1, 2, 0, 0, 0, 3}) {
+		t.Fatal(fmt.Sprintf("Wrong write past the end: %d, %v, %v", n, err, m.Bytes()))
+	}
+	if _, err := m.WriteAt([]byte{4, 5}, 32); err != nil || m.Len() != 34 || m.Bytes()[33] != 5 || m.Bytes()[20] != 0 {
+		t.Fatal(fmt.Sprintf("Wrong growth: %v, %v", err, m.Bytes()))
+	}
+
+	p := make([]byte, 4)
+	if n, err := m.ReadAt(p, 0); n != 4 || err != nil || !bytes.Equal(p, []byte{0, 1, 2, 0}) {
+		t.Fatal(fmt.Sprintf("Wrong read: %d, %v, %v", n, err, p))
+	}
+	if n, err := m.ReadAt(p, 32); n != 2 || err != io.EOF || !bytes.Equal(p[:2], []byte{4, 5}) {
+		t.Fatal(fmt.Sprintf("A read at the end must give the bytes left and io.EOF: %d, %v", n, err))
+	}
+	if n, err := m.ReadAt(p, 34); n != 0 || err != io.EOF {
+		t.Fatal(fmt.Sprintf("A read past the end must give io.EOF: %d, %v", n, err))
+	}
+	if _, err := m.ReadAt(p, -1); err == nil {
+		t.Fatal("A negative offset must be refused")
+	}
+	if _, err := m.WriteAt(p, -1); err == nil {
+		t.Fatal("A negative offset must be refused")
+	}
+
+	// the cache marks the end of the source
+	cache, err := CreateCache(1, 64, 64, 1, m, LRU)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	if data := cache.Get(0); data[33] != 5 || string(data[34:37]) != "EOF" {
+		t.Fatal(fmt.Sprintf("Wrong block at the end of the source: %v", data))
+	}
+}
@@ -0,0 +1,214 @@
+// Package metrics exports the statistics of gimc caches as Prometheus metrics and expvar variables.
+// Several caches can be exported by the same Collector, each one under its own name, given in the "cache" label.
+package metrics
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+
+	"github.com/ag0st/gimc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "gimc"
+
+var (
+	hitsDesc = prometheus.NewDesc(
+		namespace+"_hits_total", "Number of accesses found in the cache.", []string{"cache"}, nil,
+	)
+	missesDesc = prometheus.NewDesc(
+		namespace+"_misses_total", "Number of accesses not found in the cache.", []string{"cache"}, nil,
+	)
+	evictionsDesc = prometheus.NewDesc(
+		namespace+"_evictions_total", "Number of lines replaced by another one.", []string{"cache"}, nil,
+	)
+	bytesReadDesc = prometheus.NewDesc(
+		namespace+"_source_read_bytes_total", "Number of bytes read from the datasource.", []string{"cache"}, nil,
+	)
+	hitRatioDesc = prometheus.NewDesc(
+		namespace+"_hit_ratio", "Hits over accesses since the last reset of the counters.", []string{"cache"}, nil,
+	)
+	missRatioDesc = prometheus.NewDesc(
+		namespace+"_miss_ratio", "Misses over accesses since the last reset of the counters.", []string{"cache"}, nil,
+	)
+	policySetsDesc = prometheus.NewDesc(
+		namespace+"_policy_sets", "Number of sets currently using the replacement policy.", []string{"cache", "policy"}, nil,
+	)
+	policyHitRatioDesc = prometheus.NewDesc(
+		namespace+"_policy_hit_ratio", "Hit ratio of the sets using the replacement policy.", []string{"cache", "policy"}, nil,
+	)
+	shadowHitRatioDesc = prometheus.NewDesc(
+		namespace+"_shadow_hit_ratio", "Hit ratio the shadow cache would have had.", []string{"cache", "shadow"}, nil,
+	)
+	sourceCallsDesc = prometheus.NewDesc(
+		namespace+"_source_calls_total", "Number of reads or writes of the datasource.", []string{"cache", "op"}, nil,
+	)
+	sourceBytesDesc = prometheus.NewDesc(
+		namespace+"_source_bytes_total", "Number of bytes read or written by the datasource.", []string{"cache", "op"}, nil,
+	)
+	sourceErrorsDesc = prometheus.NewDesc(
+		namespace+"_source_errors_total", "Number of reads or writes of the datasource that failed.", []string{"cache", "op"}, nil,
+	)
+	sourceInFlightDesc = prometheus.NewDesc(
+		namespace+"_source_in_flight", "Number of reads or writes of the datasource not returned yet.", []string{"cache", "op"}, nil,
+	)
+	sourceDurationDesc = prometheus.NewDesc(
+		namespace+"_source_duration_seconds", "Latency of the reads or writes of the datasource.", []string{"cache", "op"}, nil,
+	)
+	missDurationDesc = prometheus.NewDesc(
+		namespace+"_miss_duration_seconds", "Time spent by the cache handling a miss, reading the datasource included.",
+		[]string{"cache"}, nil,
+	)
+)
+
+// durationBuckets are the upper bounds in seconds of the buckets of the latency histograms, 1µs to 262ms
+var durationBuckets = prometheus.ExponentialBuckets(1e-6, 4, 10)
+
+// Collector exports the statistics of named caches. It implements prometheus.Collector.
+// The statistics of the datasource (gimc.Stats.Source) are exported for the caches created with a
+// gimc.InstrumentedDatasource.
+type Collector struct {
+	mu     sync.RWMutex
+	caches map[string]*gimc.Cache
+}
+
+// NewCollector creates a collector without any cache
+func NewCollector() *Collector {
+	return &Collector{caches: make(map[string]*gimc.Cache)}
+}
+
+// Add exports the cache under this name
+func (c *Collector) Add(name string, cache *gimc.Cache) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.caches[name]; ok {
+		return errors.New(fmt.Sprintf("METRICS: A cache named %s is already exported", name))
+	}
+	c.caches[name] = cache
+	return nil
+}
+
+// Remove stops exporting the cache with this name
+func (c *Collector) Remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.caches, name)
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- hitsDesc
+	ch <- missesDesc
+	ch <- evictionsDesc
+	ch <- bytesReadDesc
+	ch <- hitRatioDesc
+	ch <- missRatioDesc
+	ch <- policySetsDesc
+	ch <- policyHitRatioDesc
+	ch <- shadowHitRatioDesc
+	ch <- sourceCallsDesc
+	ch <- sourceBytesDesc
+	ch <- sourceErrorsDesc
+	ch <- sourceInFlightDesc
+	ch <- sourceDurationDesc
+	ch <- missDurationDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for name, cache := range c.caches {
+		stats := cache.Stats()
+		ch <- prometheus.MustNewConstMetric(hitsDesc, prometheus.CounterValue, float64(stats.Hits), name)
+		ch <- prometheus.MustNewConstMetric(missesDesc, prometheus.CounterValue, float64(stats.Misses), name)
+		ch <- prometheus.MustNewConstMetric(evictionsDesc, prometheus.CounterValue, float64(stats.Evictions), name)
+		ch <- prometheus.MustNewConstMetric(bytesReadDesc, prometheus.CounterValue, float64(stats.BytesRead), name)
+		ch <- prometheus.MustNewConstMetric(hitRatioDesc, prometheus.GaugeValue, hitRatio(stats.SetStats), name)
+		ch <- prometheus.MustNewConstMetric(missRatioDesc, prometheus.GaugeValue, missRatio(stats.SetStats), name)
+		for _, ps := range stats.Policies {
+			pol := ps.Policy.String()
+			ch <- prometheus.MustNewConstMetric(policySetsDesc, prometheus.GaugeValue, float64(ps.Sets), name, pol)
+			ch <- prometheus.MustNewConstMetric(policyHitRatioDesc, prometheus.GaugeValue, hitRatio(ps.SetStats), name, pol)
+		}
+		for _, ss := range stats.Shadows {
+			ch <- prometheus.MustNewConstMetric(shadowHitRatioDesc, prometheus.GaugeValue, ss.HitRate(), name, ss.Name)
+		}
+		if stats.Source != nil {
+			collectOp(ch, stats.Source.Reads, name, "read")
+			collectOp(ch, stats.Source.Writes, name, "write")
+			ch <- durationHistogram(missDurationDesc, stats.Source.Misses, name)
+		}
+	}
+}
+
+// collectOp sends the metrics of the reads or writes of the datasource of a cache
+func collectOp(ch chan<- prometheus.Metric, s gimc.OpStats, name, op string) {
+	ch <- prometheus.MustNewConstMetric(sourceCallsDesc, prometheus.CounterValue, float64(s.Calls), name, op)
+	ch <- prometheus.MustNewConstMetric(sourceBytesDesc, prometheus.CounterValue, float64(s.Bytes), name, op)
+	ch <- prometheus.MustNewConstMetric(sourceErrorsDesc, prometheus.CounterValue, float64(s.Errors), name, op)
+	ch <- prometheus.MustNewConstMetric(sourceInFlightDesc, prometheus.GaugeValue, float64(s.InFlight), name, op)
+	ch <- durationHistogram(sourceDurationDesc, s, name, op)
+}
+
+// durationHistogram gives the histogram of the latency of the calls with the fixed durationBuckets,
+// each bucket of gimc.OpStats being counted in the first bucket bounding it
+func durationHistogram(desc *prometheus.Desc, s gimc.OpStats, labels ...string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(durationBuckets))
+	for _, bound := range durationBuckets {
+		for _, b := range s.Latency {
+			if b.UpperBound.Seconds() <= bound {
+				buckets[bound] += b.Count
+			}
+		}
+	}
+	return prometheus.MustNewConstHistogram(desc, s.Calls, s.Total.Seconds(), buckets, labels...)
+}
+
+// PublishExpvar publishes the statistics of the exported caches as an expvar variable with this name,
+// a map of the statistics of each cache by name.
+func (c *Collector) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return errors.New(fmt.Sprintf("METRICS: The expvar variable %s is already published", name))
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		vars := make(map[string]expvarStats, len(c.caches))
+		for cacheName, cache := range c.caches {
+			stats := cache.Stats()
+			vars[cacheName] = expvarStats{
+				SetStats:  stats.SetStats,
+				HitRatio:  hitRatio(stats.SetStats),
+				MissRatio: missRatio(stats.SetStats),
+				Policies:  stats.Policies,
+			}
+		}
+		return vars
+	}))
+	return nil
+}
+
+// expvarStats are the statistics of a cache published with expvar, without the details of each set
+type expvarStats struct {
+	gimc.SetStats
+	HitRatio  float64            `json:"hit_ratio"`
+	MissRatio float64            `json:"miss_ratio"`
+	Policies  []gimc.PolicyStats `json:"policies"`
+}
+
+func hitRatio(s gimc.SetStats) float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func missRatio(s gimc.SetStats) float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Misses) / float64(s.Hits+s.Misses)
+}
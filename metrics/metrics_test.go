@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ag0st/gimc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newCaches creates two caches exported by the collector, with 3 misses and 1 hit for "a" and 1 miss for "b"
+func newCaches(t *testing.T, collector *Collector) {
+	for _, name := range []string{"a", "b"} {
+		source := gimc.NewInstrumentedDatasource(gimc.NewMemoryDatasource(make([]byte, 4096)))
+		cache, err := gimc.CreateCache(2, 64, 8, 2, source, gimc.LRU,
+			gimc.WithShadow("tiny", gimc.Geometry{Sets: 1, Ways: 1, BlockSize: 64, Policy: gimc.LRU}))
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+		}
+		if err := collector.Add(name, cache); err != nil {
+			t.Fatal(fmt.Sprintf("Cannot add cache: %s", err))
+		}
+		cache.Get(0)
+		if name == "a" {
+			cache.Get(64)
+			cache.Get(128)
+			cache.Get(0)
+		}
+	}
+}
+
+func TestPrometheus(t *testing.T) {
+	collector := NewCollector()
+	newCaches(t, collector)
+	if collector.Add("a", nil) == nil {
+		t.Fatal("Names must be unique")
+	}
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot scrape: %s", err))
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, line := range []string{
+		`gimc_hits_total{cache="a"} 1`,
+		`gimc_misses_total{cache="a"} 3`,
+		`gimc_misses_total{cache="b"} 1`,
+		`gimc_evictions_total{cache="a"} 0`,
+		`gimc_source_read_bytes_total{cache="a"} 192`,
+		`gimc_hit_ratio{cache="a"} 0.25`,
+		`gimc_miss_ratio{cache="b"} 1`,
+		`gimc_policy_sets{cache="a",policy="LRU"} 2`,
+		`gimc_shadow_hit_ratio{cache="a",shadow="tiny"} 0`,
+		`gimc_source_calls_total{cache="a",op="read"} 3`,
+		`gimc_source_bytes_total{cache="a",op="read"} 192`,
+		`gimc_source_errors_total{cache="a",op="read"} 0`,
+		`gimc_source_in_flight{cache="a",op="read"} 0`,
+		`gimc_source_calls_total{cache="b",op="write"} 0`,
+		`gimc_source_duration_seconds_count{cache="a",op="read"} 3`,
+		`gimc_source_duration_seconds_bucket{cache="a",op="read",le="+Inf"} 3`,
+		`gimc_miss_duration_seconds_count{cache="a"} 3`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatal(fmt.Sprintf("Missing %s in:\n%s", line, body))
+		}
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	collector := NewCollector()
+	newCaches(t, collector)
+	if err := collector.PublishExpvar("gimc_test"); err != nil {
+		t.Fatal(fmt.Sprintf("Cannot publish: %s", err))
+	}
+	if collector.PublishExpvar("gimc_test") == nil {
+		t.Fatal("Cannot publish twice under the same name")
+	}
+	rec := httptest.NewRecorder()
+	expvar.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+	var vars struct {
+		Caches map[string]expvarStats `json:"gimc_test"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatal(fmt.Sprintf("Cannot decode expvar: %s", err))
+	}
+	if a := vars.Caches["a"]; a.Hits != 1 || a.Misses != 3 || a.HitRatio != 0.25 {
+		t.Fatal(fmt.Sprintf("Wrong statistics for a: %+v", a))
+	}
+	if b := vars.Caches["b"]; b.Misses != 1 {
+		t.Fatal(fmt.Sprintf("Wrong statistics for b: %+v", b))
+	}
+}
@@ -0,0 +1,133 @@
+package gimc
+
+import (
+	"errors"
+	"github.com/ag0st/gimc/pkg/heap"
+	"log/slog"
+	"math"
+	"sort"
+)
+
+const optNever = math.MaxUint32 // next use of a block not accessed again in the trace
+
+// optTrace is the address trace known in advance by the OPT policy, shared by the sets.
+// The accesses made to the cache must follow the trace, an access differing from the trace
+// is considered as never used again.
+type optTrace struct {
+	blocks  []uint32 // block address of each access of the trace
+	next    []uint32 // position in the trace of the next access to the same block
+	pos     int      // position in the trace of the current access
+	nextUse uint32   // position of the next use of the block of the current access
+}
+
+// newOptTrace computes the next use of every access of the trace, blocks being aligned with offsetMask
+func newOptTrace(trace []uint32, offsetMask uint32) *optTrace {
+	t := &optTrace{
+		blocks: make([]uint32, len(trace)),
+		next:   make([]uint32, len(trace)),
+	}
+	last := make(map[uint32]uint32)
+	for i := len(trace) - 1; i >= 0; i-- {
+		block := trace[i] & ^offsetMask
+		t.blocks[i] = block
+		if n, ok := last[block]; ok {
+			t.next[i] = n
+		} else {
+			t.next[i] = optNever
+		}
+		last[block] = uint32(i)
+	}
+	return t
+}
+
+// step must be called for every access of the cache, before the access
+func (t *optTrace) step(block uint32) {
+	if t.pos < len(t.blocks) && t.blocks[t.pos] == block {
+		t.nextUse = t.next[t.pos]
+	} else {
+		t.nextUse = optNever
+	}
+	t.pos++
+}
+
+// WithTrace gives the trace of addresses that will be accessed, in order, to the OPT policy
+func WithTrace(trace []uint32) Option {
+	return func(c *Cache) error {
+		c.trace = newOptTrace(trace, c.offsetMask)
+		return nil
+	}
+}
+
+// opt is the structure used to implement and represent Belady's optimal replacement policy (MIN/OPT).
+// The replaced tag is the one whose next use is the farthest in the future.
+type opt struct {
+	trace  *optTrace
+	heap   *heap.IndexedHeap[uint32, [2]uint32] // [next use, tag], the root is the farthest next use
+	logger *slog.Logger
+}
+
+func newOPT(trace *optTrace, maxWays uint16, logger *slog.Logger) *opt {
+	return &opt{
+		trace:  trace,
+		logger: logger,
+		heap: heap.NewIndexedHeap(
+			int(maxWays),
+			func(a, b [2]uint32) bool { return a[0] < b[0] },
+			func(val [2]uint32) uint32 { return val[1] },
+			heap.MaxHeap(),
+		),
+	}
+}
+
+func (o *opt) toReplace() uint32 {
+	farthest, _ := o.heap.Pop()
+	return farthest[1]
+}
+
+func (o *opt) victim() uint32 {
+	farthest, _ := o.heap.Peek()
+	return farthest[1]
+}
+
+func (o *opt) hit(tag uint32) {
+	_ = o.heap.Update([2]uint32{o.trace.nextUse, tag})
+}
+
+func (o *opt) miss(tag uint32) {
+	err := o.heap.Add([2]uint32{o.trace.nextUse, tag})
+	if err != nil {
+		o.logger.Error("OPT cannot add the tag to its heap", slog.Uint64("tag", uint64(tag)), slog.String("error", err.Error()))
+	}
+}
+
+// OptimalHitRate replays the trace of addresses on a cache of the given geometry using the OPT policy
+// and gives the best hit rate any policy can get, with its counters of hits and misses.
+func OptimalHitRate(sets, blockSize, ways uint16, trace []uint32) (rate float64, hits, misses uint64, err error) {
+	if len(trace) == 0 {
+		return 0, 0, 0, errors.New("CACHE: The trace is empty")
+	}
+	c, err := newCache(sets, blockSize, blockSize, ways, nil, OPT, WithTrace(trace))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	for _, address := range trace {
+		c.Get(address)
+	}
+	hits, misses = c.GetCounters()
+	return float64(hits) / float64(hits+misses), hits, misses, nil
+}
+
+// describe gives the tags with their next use, from the farthest one
+func (o *opt) describe() []policyLine {
+	values := o.heap.Values()
+	sort.Slice(values, func(i, j int) bool { return values[i][0] > values[j][0] })
+	lines := make([]policyLine, len(values))
+	for i, v := range values {
+		lines[i] = policyLine{tag: v[1], value: int(v[0])}
+	}
+	return lines
+}
+
+func (o *opt) remove(tag uint32) {
+	o.heap.Remove(tag)
+}
@@ -0,0 +1,82 @@
+package gimc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// setConfig is the replacement policy and the associativity of a set
+type setConfig struct {
+	pol  RePol
+	ways uint16
+}
+
+// region is a range of addresses with its own sets, replacement policy and associativity
+type region struct {
+	start, end uint32 // range of addresses [start, end)
+	config     setConfig
+	sets       []*set
+}
+
+// WithSetPolicy gives its own replacement policy and associativity to the sets at these indices.
+// If ways is 0, the sets keep the associativity of the cache. These sets do not take part in set dueling.
+func WithSetPolicy(indices []uint16, pol RePol, ways uint16) Option {
+	return func(c *Cache) error {
+		w := ways // the option may be given to several caches
+		if w == 0 {
+			w = c.maxWays
+		}
+		if c.setConfigs == nil {
+			c.setConfigs = make(map[uint32]setConfig)
+		}
+		for _, index := range indices {
+			if int(index) >= len(c.sets) {
+				return errors.New(fmt.Sprintf("OVERRIDE: Set index %d out of range (%d sets)", index, len(c.sets)))
+			}
+			c.setConfigs[uint32(index)] = setConfig{pol: pol, ways: w}
+		}
+		return nil
+	}
+}
+
+// WithRegionPolicy gives the addresses in [start, end) their own sets, with the same geometry as the cache
+// but their own replacement policy and associativity. If ways is 0, the region keeps the associativity
+// of the cache. The blocks of the region never compete with the other blocks.
+// start and end must be multiples of the block size, so that a block is either in the region or out of it.
+func WithRegionPolicy(start, end uint32, pol RePol, ways uint16) Option {
+	return func(c *Cache) error {
+		if start >= end {
+			return errors.New(fmt.Sprintf("OVERRIDE: Empty region [%d, %d)", start, end))
+		}
+		if start&c.offsetMask != 0 || end&c.offsetMask != 0 {
+			// a block across a bound would be cached in the region and in the other sets
+			return errors.New(fmt.Sprintf("OVERRIDE: Region [%d, %d) not aligned on the blocks of %d bytes", start, end, c.blockSize))
+		}
+		for _, r := range c.regions {
+			if start < r.end && r.start < end {
+				return errors.New(fmt.Sprintf("OVERRIDE: Region [%d, %d) overlaps [%d, %d)", start, end, r.start, r.end))
+			}
+		}
+		w := ways // the option may be given to several caches
+		if w == 0 {
+			w = c.maxWays
+		}
+		c.regions = append(c.regions, &region{
+			start:  start,
+			end:    end,
+			config: setConfig{pol: pol, ways: w},
+		})
+		return nil
+	}
+}
+
+// setsOf gives the sets in which the block of the address is stored
+func (c *Cache) setsOf(address uint32) []*set {
+	block := address & ^c.offsetMask
+	for _, r := range c.regions {
+		if block >= r.start && block < r.end {
+			return r.sets
+		}
+	}
+	return c.sets
+}
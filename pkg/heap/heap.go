@@ -5,104 +5,197 @@ import (
     "fmt"
 )
 
-type Heap struct {
-    harr        [][2]uint32
-    maxSize     int
-    currentSize int
+// Ordered is the set of types having a natural order, usable with Less and Greater
+type Ordered interface {
+    ~int | ~int8 | ~int16 | ~int32 | ~int64 |
+        ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+        ~float32 | ~float64 | ~string
 }
 
-// NewHeap create a new heap with a maximum size given in parameter
-func NewHeap(size int) *Heap {
-    return &Heap{maxSize: size, currentSize: 0}
+// Less is the natural ordering of ordered types
+func Less[T Ordered](a, b T) bool {
+    return a < b
 }
 
-// percolateUp push up the element at position i by swapping until it is at the right position
-func (h *Heap) percolateUp(i int) {
-    for i > 0 && h.harr[parent(i)][0] > h.harr[i][0] {
-        h.harr[i], h.harr[parent(i)] = h.harr[parent(i)], h.harr[i]
-        i = parent(i)
+// Greater is the reverse of the natural ordering of ordered types
+func Greater[T Ordered](a, b T) bool {
+    return a > b
+}
+
+// Option is an optional setting of a heap, given at its creation
+type Option func(c *config)
+
+type config struct {
+    arity     int
+    unbounded bool
+    max       bool
+}
+
+// WithArity sets the number of children of each node (d-ary heap), 2 by default.
+// A bigger arity makes Add faster and Pop slower.
+func WithArity(d int) Option {
+    return func(c *config) {
+        if d >= 2 {
+            c.arity = d
+        }
     }
 }
 
-// siftDown push down the element at position i by swapping until  it is at the right position
-func (h *Heap) siftDown(i int) {
-    l := leftChild(i)
-    r := rightChild(i)
-    smallest := i
-    if r < len(h.harr) && h.harr[r][0] < h.harr[i][0] {
-        smallest = r
+// Unbounded lets the heap grow without limit, the size given at the creation is only a capacity hint
+func Unbounded() Option {
+    return func(c *config) {
+        c.unbounded = true
     }
-    if l < len(h.harr) && h.harr[l][0] < h.harr[smallest][0] {
-        smallest = l
+}
+
+// MaxHeap reverses the ordering, the root is then the biggest element regarding less
+func MaxHeap() Option {
+    return func(c *config) {
+        c.max = true
+    }
+}
+
+func newConfig(opts []Option) config {
+    c := config{arity: 2}
+    for _, opt := range opts {
+        opt(&c)
+    }
+    return c
+}
+
+// ordering gives the function ordering the heap regarding the mode
+func ordering[T any](c config, less func(a, b T) bool) func(a, b T) bool {
+    if c.max {
+        return func(a, b T) bool { return less(b, a) }
+    }
+    return less
+}
+
+// Heap is a d-ary heap of elements ordered by a less function, the root being the smallest element
+// (or the biggest with MaxHeap).
+type Heap[T any] struct {
+    harr    []T
+    before  func(a, b T) bool // tells if a must be closer to the root than b
+    arity   int
+    maxSize int // maximum number of elements, -1 if unbounded
+}
+
+// NewHeap create a new heap with a maximum size and an ordering given in parameter.
+// The size is always enforced, a heap of size 0 refuses every element: Unbounded is the only way to lift the limit.
+func NewHeap[T any](size int, less func(a, b T) bool, opts ...Option) *Heap[T] {
+    c := newConfig(opts)
+    h := &Heap[T]{
+        harr:   make([]T, 0, size),
+        before: ordering(c, less),
+        arity:  c.arity,
     }
-    if smallest != i {
-        h.harr[i], h.harr[smallest] = h.harr[smallest], h.harr[i]
-        h.siftDown(smallest)
+    h.maxSize = size
+    if c.unbounded {
+        h.maxSize = -1
     }
+    return h
 }
 
-func (h *Heap) Size() int {
-    return h.currentSize
+// Len gives the number of elements in the heap
+func (h *Heap[T]) Len() int {
+    return len(h.harr)
 }
 
-// Add an element in the heap. The priority (key used for the heap) is the first element of the parameter "val"
-func (h *Heap) Add(val [2]uint32) error {
-    if h.currentSize == h.maxSize {
-        return errors.New(fmt.Sprintf("HEAP: Max size reached (%d/%d)", h.currentSize, h.maxSize))
+// Add an element in the heap
+func (h *Heap[T]) Add(val T) error {
+    if h.maxSize >= 0 && len(h.harr) >= h.maxSize {
+        return errors.New(fmt.Sprintf("HEAP: Max size reached (%d/%d)", len(h.harr), h.maxSize))
     }
     h.harr = append(h.harr, val)
     h.percolateUp(len(h.harr) - 1)
-    h.currentSize++
     return nil
 }
 
-// RemoveMin removes the min element regarding its key (first element of the arrays present in heap)
-func (h *Heap) RemoveMin() [2]uint32 {
-    if h.currentSize == 0 {
-        return [2]uint32{}
+// Peek gives the root element (min, or max in max mode) without removing it, false if the heap is empty
+func (h *Heap[T]) Peek() (T, bool) {
+    if len(h.harr) == 0 {
+        var zero T
+        return zero, false
     }
-    // store the root (minimum)
-    min := h.harr[0]
-    // put the max to the top and sift down
-    h.harr[0] = h.harr[len(h.harr) - 1]
-    h.harr = h.harr[:len(h.harr) - 1] // remove the last element
+    return h.harr[0], true
+}
+
+// Pop removes and gives the root element (min, or max in max mode), false if the heap is empty
+func (h *Heap[T]) Pop() (T, bool) {
+    if len(h.harr) == 0 {
+        var zero T
+        return zero, false
+    }
+    // store the root
+    root := h.harr[0]
+    // put the last to the top and sift down
+    last := len(h.harr) - 1
+    h.harr[0] = h.harr[last]
+    var zero T
+    h.harr[last] = zero // do not keep a reference
+    h.harr = h.harr[:last]
     h.siftDown(0)
-    h.currentSize--
-    return min
-}
-
-// Update the data given in parameter, assuming the second element of the data is the key and must already be present
-// in the heap.
-// This is not a treap, so update takes 0(n)
-func (h *Heap) Update(data [2]uint32)  {
-    var i int
-    var val [2]uint32
-    for i, val = range h.harr {
-        if val[1] == data[1] {
-            break
+    return root, true
+}
+
+// Update replaces the first element for which match returns true by val.
+// This is not an indexed heap (see IndexedHeap), so update takes O(n). Returns false if nothing matched.
+func (h *Heap[T]) Update(match func(T) bool, val T) bool {
+    for i := range h.harr {
+        if match(h.harr[i]) {
+            h.harr[i] = val
+            // the element can go in both directions
+            h.siftDown(i)
+            h.percolateUp(i)
+            return true
         }
     }
-    // now update the new regarding increase / decrease
-    if val[0] < data[0] { // increase, sift down
-        h.harr[i][0] = data[0]
-        h.siftDown(i)
-    } else if val[0] > data[0] { // decrease, percolate up
-        h.harr[i][0] = data[0]
-        h.percolateUp(i)
+    return false
+}
+
+// Clear removes all the elements of the heap
+func (h *Heap[T]) Clear() {
+    var zero T
+    for i := range h.harr {
+        h.harr[i] = zero
+    }
+    h.harr = h.harr[:0]
+}
+
+// percolateUp push up the element at position i by swapping until it is at the right position
+func (h *Heap[T]) percolateUp(i int) {
+    for i > 0 && h.before(h.harr[i], h.harr[parent(i, h.arity)]) {
+        p := parent(i, h.arity)
+        h.harr[i], h.harr[p] = h.harr[p], h.harr[i]
+        i = p
     }
 }
 
-// parent give the parent index of the element at the index i
-func parent(i int) int {
-    return (i - 1) / 2
+// siftDown push down the element at position i by swapping until it is at the right position
+func (h *Heap[T]) siftDown(i int) {
+    for {
+        first := firstChild(i, h.arity)
+        best := i
+        for c := first; c < first+h.arity && c < len(h.harr); c++ {
+            if h.before(h.harr[c], h.harr[best]) {
+                best = c
+            }
+        }
+        if best == i {
+            return
+        }
+        h.harr[i], h.harr[best] = h.harr[best], h.harr[i]
+        i = best
+    }
 }
 
-// leftChild gives the left child of the element at index i, may be out of bound if not exists
-func leftChild(i int) int {
-    return 2*i + 1
+// parent give the parent index of the element at the index i in a heap of the given arity
+func parent(i, arity int) int {
+    return (i - 1) / arity
 }
 
-// rightChild gives the right child of the element at index i, may be out of bound if not exists
-func rightChild(i int) int {
-    return 2*i + 2
+// firstChild gives the first child of the element at index i in a heap of the given arity,
+// may be out of bound if not exists
+func firstChild(i, arity int) int {
+    return arity*i + 1
 }
@@ -2,37 +2,99 @@ package heap
 
 import (
     "math/rand"
+    "sort"
     "testing"
 )
 
+// byPriority orders [2]uint32 elements on their first element, the second one being the key
+func byPriority(a, b [2]uint32) bool {
+    return a[0] < b[0]
+}
+
+func keyOf(val [2]uint32) uint32 {
+    return val[1]
+}
+
 func TestHeap(t *testing.T) {
-    heap := NewHeap(10)
-    for i := uint32(0); i < 10; i++ {
+    heap := NewHeap(10, byPriority)
+    for i, p := range rand.Perm(10) {
         data := [2]uint32{
-            uint32(rand.Intn(15)) + 1, // 1..15
-            i,
+            uint32(p) + 1, // 1..10
+            uint32(i),
         }
         err := heap.Add(data)
         if err != nil {
             t.Fatal("Must be capable of adding element")
         }
     }
+    if heap.Add([2]uint32{1, 10}) == nil {
+        t.Fatal("Must not add more than the max size")
+    }
 
-    min := heap.RemoveMin()
+    min, _ := heap.Pop()
     _ = heap.Add(min)
-    min2 := heap.RemoveMin()
+    min2, _ := heap.Pop()
     if min[0] != min2[0] || min[1] != min2[1] {
         t.Fatal("Must be the same")
     }
     data := [2]uint32{
-        0, // the min added so far
+        0,  // the min added so far
         10, // only one
     }
     heap.Add(data)
     data[0] = 15
-    heap.Update(data)
-    removeMin := heap.RemoveMin()
+    heap.Update(func(val [2]uint32) bool { return val[1] == data[1] }, data)
+    removeMin, _ := heap.Pop()
     if removeMin[1] == data[1] {
         t.Fatal("Not sifted down when updated")
     }
-}
\ No newline at end of file
+}
+
+func TestHeapPeekLenClear(t *testing.T) {
+    heap := NewHeap(10, Less[int])
+    if _, ok := heap.Peek(); ok {
+        t.Fatal("Empty heap has nothing to peek")
+    }
+    for _, v := range []int{5, 3, 8} {
+        _ = heap.Add(v)
+    }
+    if v, ok := heap.Peek(); !ok || v != 3 || heap.Len() != 3 {
+        t.Fatal("Peek must give the min without removing it")
+    }
+    heap.Clear()
+    if _, ok := heap.Pop(); ok || heap.Len() != 0 {
+        t.Fatal("Heap must be empty after Clear")
+    }
+}
+
+func TestHeapZeroSize(t *testing.T) {
+    if NewHeap(0, Less[int]).Add(1) == nil || NewIndexedHeap(0, byPriority, keyOf).Add([2]uint32{1, 1}) == nil {
+        t.Fatal("A heap of size 0 must refuse every element")
+    }
+    if NewHeap(0, Less[int], Unbounded()).Add(1) != nil {
+        t.Fatal("An unbounded heap must grow")
+    }
+}
+
+func TestHeapModes(t *testing.T) {
+    values := rand.Perm(1000)
+    for _, arity := range []int{2, 3, 4, 8} {
+        min := NewHeap(0, Less[int], WithArity(arity), Unbounded())
+        max := NewHeap(0, Less[int], WithArity(arity), Unbounded(), MaxHeap())
+        for _, v := range values {
+            if min.Add(v) != nil || max.Add(v) != nil {
+                t.Fatal("Unbounded heap must accept any number of elements")
+            }
+        }
+        sorted := append([]int(nil), values...)
+        sort.Ints(sorted)
+        for i := range sorted {
+            if v, _ := min.Pop(); v != sorted[i] {
+                t.Fatalf("Min heap of arity %d not in order", arity)
+            }
+            if v, _ := max.Pop(); v != sorted[len(sorted)-1-i] {
+                t.Fatalf("Max heap of arity %d not in order", arity)
+            }
+        }
+    }
+}
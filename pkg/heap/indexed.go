@@ -0,0 +1,180 @@
+package heap
+
+import (
+    "errors"
+    "fmt"
+)
+
+// IndexedHeap is a d-ary heap like Heap, which also keeps the position of the key of each element.
+// An element can then be found in O(1) and updated or removed in O(log n). Keys must be unique in the heap.
+type IndexedHeap[K comparable, T any] struct {
+    harr    []T
+    pos     map[K]int // position in harr of each key
+    key     func(T) K
+    before  func(a, b T) bool
+    arity   int
+    maxSize int // maximum number of elements, -1 if unbounded
+}
+
+// NewIndexedHeap create a new indexed heap with a maximum size, an ordering and a function giving the key of an element
+// As for NewHeap, the size is always enforced unless the heap is Unbounded.
+func NewIndexedHeap[K comparable, T any](size int, less func(a, b T) bool, key func(T) K, opts ...Option) *IndexedHeap[K, T] {
+    c := newConfig(opts)
+    h := &IndexedHeap[K, T]{
+        harr:   make([]T, 0, size),
+        pos:    make(map[K]int, size),
+        key:    key,
+        before: ordering(c, less),
+        arity:  c.arity,
+    }
+    h.maxSize = size
+    if c.unbounded {
+        h.maxSize = -1
+    }
+    return h
+}
+
+// Len gives the number of elements in the heap
+func (h *IndexedHeap[K, T]) Len() int {
+    return len(h.harr)
+}
+
+// Contains tells if an element with this key is in the heap
+func (h *IndexedHeap[K, T]) Contains(key K) bool {
+    _, ok := h.pos[key]
+    return ok
+}
+
+// Get gives the element with this key, false if not in the heap
+func (h *IndexedHeap[K, T]) Get(key K) (T, bool) {
+    i, ok := h.pos[key]
+    if !ok {
+        var zero T
+        return zero, false
+    }
+    return h.harr[i], true
+}
+
+// Values gives a copy of the elements of the heap, in no particular order
+func (h *IndexedHeap[K, T]) Values() []T {
+    return append([]T(nil), h.harr...)
+}
+
+// Add an element in the heap, its key must not already be present
+func (h *IndexedHeap[K, T]) Add(val T) error {
+    if h.maxSize >= 0 && len(h.harr) >= h.maxSize {
+        return errors.New(fmt.Sprintf("HEAP: Max size reached (%d/%d)", len(h.harr), h.maxSize))
+    }
+    k := h.key(val)
+    if h.Contains(k) {
+        return errors.New(fmt.Sprintf("HEAP: Key %v already present", k))
+    }
+    h.harr = append(h.harr, val)
+    h.pos[k] = len(h.harr) - 1
+    h.percolateUp(len(h.harr) - 1)
+    return nil
+}
+
+// Peek gives the root element without removing it, false if the heap is empty
+func (h *IndexedHeap[K, T]) Peek() (T, bool) {
+    if len(h.harr) == 0 {
+        var zero T
+        return zero, false
+    }
+    return h.harr[0], true
+}
+
+// Pop removes and gives the root element, false if the heap is empty
+func (h *IndexedHeap[K, T]) Pop() (T, bool) {
+    if len(h.harr) == 0 {
+        var zero T
+        return zero, false
+    }
+    return h.removeAt(0), true
+}
+
+// Remove removes the element with this key, returns false if the key is not in the heap
+func (h *IndexedHeap[K, T]) Remove(key K) (T, bool) {
+    i, ok := h.pos[key]
+    if !ok {
+        var zero T
+        return zero, false
+    }
+    return h.removeAt(i), true
+}
+
+// Update replaces the element having the same key as val and restores the heap order.
+// Returns an error if the key is not in the heap.
+func (h *IndexedHeap[K, T]) Update(val T) error {
+    k := h.key(val)
+    i, ok := h.pos[k]
+    if !ok {
+        return errors.New(fmt.Sprintf("HEAP: Key %v not present", k))
+    }
+    h.harr[i] = val
+    // the element can go in both directions
+    h.siftDown(i)
+    h.percolateUp(i)
+    return nil
+}
+
+// Clear removes all the elements of the heap
+func (h *IndexedHeap[K, T]) Clear() {
+    var zero T
+    for i := range h.harr {
+        h.harr[i] = zero
+    }
+    h.harr = h.harr[:0]
+    h.pos = make(map[K]int, cap(h.harr))
+}
+
+// removeAt removes the element at position i and restores the heap order
+func (h *IndexedHeap[K, T]) removeAt(i int) T {
+    removed := h.harr[i]
+    last := len(h.harr) - 1
+    h.swap(i, last)
+    var zero T
+    h.harr[last] = zero // do not keep a reference
+    h.harr = h.harr[:last]
+    delete(h.pos, h.key(removed))
+    if i < last {
+        // the moved element can go in both directions
+        h.siftDown(i)
+        h.percolateUp(i)
+    }
+    return removed
+}
+
+// percolateUp push up the element at position i by swapping until it is at the right position
+func (h *IndexedHeap[K, T]) percolateUp(i int) {
+    for i > 0 && h.before(h.harr[i], h.harr[parent(i, h.arity)]) {
+        p := parent(i, h.arity)
+        h.swap(i, p)
+        i = p
+    }
+}
+
+// siftDown push down the element at position i by swapping until it is at the right position
+func (h *IndexedHeap[K, T]) siftDown(i int) {
+    for {
+        first := firstChild(i, h.arity)
+        best := i
+        for c := first; c < first+h.arity && c < len(h.harr); c++ {
+            if h.before(h.harr[c], h.harr[best]) {
+                best = c
+            }
+        }
+        if best == i {
+            return
+        }
+        h.swap(i, best)
+        i = best
+    }
+}
+
+// swap exchanges the elements at positions i and j and updates their positions
+func (h *IndexedHeap[K, T]) swap(i, j int) {
+    h.harr[i], h.harr[j] = h.harr[j], h.harr[i]
+    h.pos[h.key(h.harr[i])] = i
+    h.pos[h.key(h.harr[j])] = j
+}
@@ -0,0 +1,129 @@
+package heap
+
+import (
+    "math/rand"
+    "sort"
+    "testing"
+)
+
+// checkIndexedHeap verifies the heap order and the positions of the keys
+func checkIndexedHeap(t *testing.T, h *IndexedHeap[uint32, [2]uint32]) {
+    for i, val := range h.harr {
+        if i > 0 && h.harr[parent(i, h.arity)][0] > val[0] {
+            t.Fatal("Heap order not respected")
+        }
+        if h.pos[val[1]] != i {
+            t.Fatal("Wrong position of the key")
+        }
+    }
+    if len(h.pos) != len(h.harr) {
+        t.Fatal("Positions of removed keys must be forgotten")
+    }
+}
+
+func TestIndexedHeapAdd(t *testing.T) {
+    heap := NewIndexedHeap(10, byPriority, keyOf)
+    for i := uint32(0); i < 10; i++ {
+        if err := heap.Add([2]uint32{uint32(rand.Intn(15)), i}); err != nil {
+            t.Fatal("Must be capable of adding element")
+        }
+        checkIndexedHeap(t, heap)
+    }
+    if heap.Add([2]uint32{0, 10}) == nil {
+        t.Fatal("Must not add more than the max size")
+    }
+    heap.Remove(3)
+    if heap.Add([2]uint32{0, 5}) == nil {
+        t.Fatal("Must not add a key twice")
+    }
+    if heap.Add([2]uint32{0, 3}) != nil {
+        t.Fatal("Must add a removed key again")
+    }
+}
+
+func TestIndexedHeapPop(t *testing.T) {
+    heap := NewIndexedHeap(100, byPriority, keyOf)
+    var priorities []int
+    for i := uint32(0); i < 100; i++ {
+        p := rand.Intn(1000)
+        priorities = append(priorities, p)
+        _ = heap.Add([2]uint32{uint32(p), i})
+    }
+    sort.Ints(priorities)
+    for _, p := range priorities {
+        min, ok := heap.Peek()
+        if !ok || min[0] != uint32(p) {
+            t.Fatal("Peek must give the smallest priority")
+        }
+        if removed, _ := heap.Pop(); removed != min {
+            t.Fatal("Pop must remove the min")
+        }
+        checkIndexedHeap(t, heap)
+    }
+    if _, ok := heap.Peek(); ok || heap.Len() != 0 {
+        t.Fatal("Heap must be empty")
+    }
+}
+
+func TestIndexedHeapContainsGet(t *testing.T) {
+    heap := NewIndexedHeap(10, byPriority, keyOf)
+    _ = heap.Add([2]uint32{3, 7})
+    if !heap.Contains(7) || heap.Contains(8) {
+        t.Fatal("Contains must find only the added keys")
+    }
+    if val, ok := heap.Get(7); !ok || val[0] != 3 {
+        t.Fatal("Get must give the element of the key")
+    }
+    if _, ok := heap.Get(8); ok {
+        t.Fatal("Get must not find a missing key")
+    }
+    if values := heap.Values(); len(values) != 1 || values[0] != [2]uint32{3, 7} {
+        t.Fatal("Values must give the elements of the heap")
+    }
+}
+
+func TestIndexedHeapUpdate(t *testing.T) {
+    heap := NewIndexedHeap(20, byPriority, keyOf)
+    for i := uint32(0); i < 20; i++ {
+        _ = heap.Add([2]uint32{i + 10, i})
+    }
+    // decrease
+    _ = heap.Update([2]uint32{0, 15})
+    checkIndexedHeap(t, heap)
+    if min, _ := heap.Peek(); min[1] != 15 {
+        t.Fatal("Not percolated up when decreased")
+    }
+    // increase
+    _ = heap.Update([2]uint32{100, 15})
+    checkIndexedHeap(t, heap)
+    if min, _ := heap.Peek(); min[1] != 0 {
+        t.Fatal("Not sifted down when increased")
+    }
+    if heap.Update([2]uint32{1, 50}) == nil {
+        t.Fatal("Must not update a missing key")
+    }
+}
+
+func TestIndexedHeapRemove(t *testing.T) {
+    heap := NewIndexedHeap(50, byPriority, keyOf, WithArity(4))
+    for i := uint32(0); i < 50; i++ {
+        _ = heap.Add([2]uint32{uint32(rand.Intn(100)), i})
+    }
+    // remove arbitrary elements
+    for _, key := range rand.Perm(50)[:25] {
+        val, ok := heap.Remove(uint32(key))
+        if !ok || val[1] != uint32(key) {
+            t.Fatal("Must remove the element of the key")
+        }
+        if heap.Contains(uint32(key)) {
+            t.Fatal("Removed key must not be present")
+        }
+        checkIndexedHeap(t, heap)
+    }
+    if _, ok := heap.Remove(1000); ok {
+        t.Fatal("Must not remove a missing key")
+    }
+    if heap.Len() != 25 {
+        t.Fatal("Wrong size after removals")
+    }
+}
@@ -0,0 +1,225 @@
+// Package reuse computes the reuse distances of a trace and the miss-ratio curve of LRU caches.
+//
+// The reuse (or stack) distance of an access is the number of distinct blocks accessed since the previous
+// access to the same block. Following Mattson's stack algorithm, a fully associative LRU cache of c blocks
+// hits exactly the accesses with a distance lower than c, so one pass over the trace gives the miss ratio
+// of every cache size.
+//
+// The distances are computed in O(log n) with a Fenwick tree over the times of the last accesses.
+// For huge traces, WithSampling only analyzes a fraction of the blocks, chosen by a hash of their address
+// as in SHARDS (Waldspurger et al., FAST 2015), and scales the distances accordingly.
+package reuse
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// samplingModulus is the modulus of the hash of the blocks used for the sampling
+const samplingModulus = 1 << 24
+
+// Option is an optional setting of the analyzer
+type Option func(a *Analyzer) error
+
+// WithSampling analyzes only a fraction of the blocks, given by rate in (0, 1].
+// The memory and the time used are reduced by the same fraction, at the cost of some accuracy.
+func WithSampling(rate float64) Option {
+	return func(a *Analyzer) error {
+		if rate <= 0 || rate > 1 {
+			return errors.New(fmt.Sprintf("REUSE: Wrong sampling rate %v, must be in (0, 1]", rate))
+		}
+		a.rate = rate
+		a.threshold = uint32(rate * samplingModulus)
+		if a.threshold == 0 {
+			a.threshold = 1
+		}
+		return nil
+	}
+}
+
+// Analyzer computes the reuse distances of the accesses given to Access. It is not safe for concurrent use.
+type Analyzer struct {
+	offsetSize uint8          // log2 of the block size
+	rate       float64        // fraction of the blocks analyzed
+	threshold  uint32         // a block is analyzed if its hash modulo samplingModulus is lower
+	last       map[uint32]int // time of the last access of each block
+	tree       fenwick        // 1 at the time of the last access of each block
+	time       int            // time of the next access, in accesses analyzed
+	histogram  Histogram
+}
+
+// NewAnalyzer creates an analyzer of the accesses to blocks of blockSize bytes, a power of 2
+func NewAnalyzer(blockSize uint32, opts ...Option) (*Analyzer, error) {
+	if blockSize == 0 || blockSize&(blockSize-1) != 0 {
+		return nil, errors.New(fmt.Sprintf("REUSE: Wrong block size %d, must be a power of 2", blockSize))
+	}
+	a := &Analyzer{
+		offsetSize: uint8(bits.TrailingZeros32(blockSize)),
+		rate:       1,
+		threshold:  samplingModulus,
+		last:       make(map[uint32]int),
+		tree:       make(fenwick, 1024),
+	}
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+	a.histogram.BlockSize = blockSize
+	a.histogram.Rate = a.rate
+	return a, nil
+}
+
+// Analyze gives the histogram of the reuse distances of the addresses
+func Analyze(addresses []uint32, blockSize uint32, opts ...Option) (Histogram, error) {
+	a, err := NewAnalyzer(blockSize, opts...)
+	if err != nil {
+		return Histogram{}, err
+	}
+	for _, address := range addresses {
+		a.Access(address)
+	}
+	return a.Histogram(), nil
+}
+
+// Access records an access to this address
+func (a *Analyzer) Access(address uint32) {
+	block := address >> a.offsetSize
+	if a.threshold < samplingModulus && hash(block)%samplingModulus >= a.threshold {
+		return
+	}
+	h := &a.histogram
+	h.Accesses++
+	if a.time == len(a.tree) {
+		a.compact()
+	}
+	previous, ok := a.last[block]
+	if !ok {
+		h.Cold++
+	} else {
+		// distinct blocks accessed after the previous access, scaled to the whole trace
+		distance := int(float64(a.tree.sum(a.time-1)-a.tree.sum(previous)) / a.rate)
+		for len(h.Distances) <= distance {
+			h.Distances = append(h.Distances, 0)
+		}
+		h.Distances[distance]++
+		a.tree.add(previous, -1)
+	}
+	a.tree.add(a.time, 1)
+	a.last[block] = a.time
+	a.time++
+}
+
+// compact renumbers the times of the last accesses from 0, keeping their order, and resizes the tree
+// to twice the number of blocks so that the memory stays proportional to the blocks and not to the accesses
+func (a *Analyzer) compact() {
+	blocks := make([]uint32, len(a.tree))
+	live := make([]bool, len(a.tree))
+	for block, t := range a.last {
+		blocks[t] = block
+		live[t] = true
+	}
+	size := 2 * len(a.last)
+	if size < 1024 {
+		size = 1024
+	}
+	a.tree = make(fenwick, size)
+	a.time = 0
+	for t, block := range blocks {
+		if live[t] {
+			a.last[block] = a.time
+			a.tree.add(a.time, 1)
+			a.time++
+		}
+	}
+}
+
+// Histogram gives the histogram of the reuse distances of the accesses so far
+func (a *Analyzer) Histogram() Histogram {
+	h := a.histogram
+	h.Distances = append([]uint64(nil), h.Distances...)
+	return h
+}
+
+// Histogram is the histogram of the reuse distances of a trace, in blocks.
+// With sampling, the counts are those of the accesses analyzed and the distances are scaled to the whole trace.
+type Histogram struct {
+	BlockSize uint32
+	Rate      float64  // fraction of the blocks analyzed
+	Accesses  uint64   // accesses analyzed
+	Cold      uint64   // first accesses to a block, missed by any cache
+	Distances []uint64 // Distances[d] is the number of accesses with a reuse distance of d blocks
+}
+
+// MissRatio gives the miss ratio of a fully associative LRU cache of this number of blocks
+func (h Histogram) MissRatio(blocks int) float64 {
+	if h.Accesses == 0 {
+		return 0
+	}
+	if blocks < 0 {
+		blocks = 0
+	}
+	misses := h.Cold
+	for d := blocks; d < len(h.Distances); d++ {
+		misses += h.Distances[d]
+	}
+	return float64(misses) / float64(h.Accesses)
+}
+
+// MissRatioCurve gives the miss ratio of the fully associative LRU caches of every size, curve[c] being the one
+// of a cache of c blocks. A cache bigger than the last size has the same miss ratio as the last size.
+func (h Histogram) MissRatioCurve() []float64 {
+	curve := make([]float64, len(h.Distances)+1)
+	if h.Accesses == 0 {
+		return curve
+	}
+	misses := h.Cold
+	for c := len(h.Distances); c >= 0; c-- {
+		curve[c] = float64(misses) / float64(h.Accesses)
+		if c > 0 {
+			misses += h.Distances[c-1]
+		}
+	}
+	return curve
+}
+
+// BlocksFor gives the number of blocks of the smallest fully associative LRU cache reaching this miss ratio,
+// -1 if even a cache holding the whole trace misses more because of the cold misses
+func (h Histogram) BlocksFor(missRatio float64) int {
+	for c, ratio := range h.MissRatioCurve() {
+		if ratio <= missRatio {
+			return c
+		}
+	}
+	return -1
+}
+
+// fenwick is a binary indexed tree giving the prefix sums of an array in O(log n)
+type fenwick []int32
+
+// add adds v to the element i
+func (f fenwick) add(i int, v int32) {
+	for i++; i <= len(f); i += i & -i {
+		f[i-1] += v
+	}
+}
+
+// sum gives the sum of the elements 0 to i included, 0 if i is negative
+func (f fenwick) sum(i int) int {
+	s := 0
+	for i++; i > 0; i -= i & -i {
+		s += int(f[i-1])
+	}
+	return s
+}
+
+// hash mixes the bits of a block address (finalizer of MurmurHash3)
+func hash(v uint32) uint32 {
+	v ^= v >> 16
+	v *= 0x85ebca6b
+	v ^= v >> 13
+	v *= 0xc2b2ae35
+	v ^= v >> 16
+	return v
+}
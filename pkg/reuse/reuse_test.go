@@ -0,0 +1,100 @@
+package reuse
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// stackDistances computes the reuse distances with an LRU stack, as Mattson did
+func stackDistances(addresses []uint32, blockSize uint32) (cold uint64, distances map[int]uint64) {
+	distances = make(map[int]uint64)
+	var stack []uint32 // most recent first
+	for _, address := range addresses {
+		block := address / blockSize
+		d := -1
+		for i, b := range stack {
+			if b == block {
+				d = i
+				break
+			}
+		}
+		if d < 0 {
+			cold++
+		} else {
+			distances[d]++
+			stack = append(stack[:d], stack[d+1:]...)
+		}
+		stack = append([]uint32{block}, stack...)
+	}
+	return cold, distances
+}
+
+func TestAnalyze(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	addresses := make([]uint32, 20_000)
+	for i := range addresses {
+		addresses[i] = uint32(rng.ExpFloat64()*100) * 64 // skewed towards the low blocks
+	}
+	// enough accesses for the analyzer to compact its tree several times
+	h, err := Analyze(addresses, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, distances := stackDistances(addresses, 64)
+	if h.Cold != cold || h.Accesses != uint64(len(addresses)) {
+		t.Fatal(fmt.Sprintf("Wrong counts: %d cold of %d accesses, want %d of %d", h.Cold, h.Accesses, cold, len(addresses)))
+	}
+	for d, n := range h.Distances {
+		if n != distances[d] {
+			t.Fatal(fmt.Sprintf("Wrong count at distance %d: %d, want %d", d, n, distances[d]))
+		}
+	}
+
+	curve := h.MissRatioCurve()
+	if curve[0] != 1 || curve[len(curve)-1] != float64(cold)/float64(len(addresses)) {
+		t.Fatal(fmt.Sprintf("Wrong ends of the curve: %v and %v", curve[0], curve[len(curve)-1]))
+	}
+	for c := 1; c < len(curve); c++ {
+		if curve[c] > curve[c-1] || curve[c] != h.MissRatio(c) {
+			t.Fatal(fmt.Sprintf("Wrong miss ratio %v for %d blocks", curve[c], c))
+		}
+	}
+	if c := h.BlocksFor(0.5); c < 0 || curve[c] > 0.5 || curve[c-1] <= 0.5 {
+		t.Fatal(fmt.Sprintf("Wrong size for a miss ratio of 0.5: %d", c))
+	}
+	if c := h.BlocksFor(0); c != -1 {
+		t.Fatal(fmt.Sprintf("No cache can avoid the cold misses, got %d blocks", c))
+	}
+}
+
+func TestSampling(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	addresses := make([]uint32, 200_000)
+	for i := range addresses {
+		addresses[i] = uint32(rng.Intn(20_000)) * 32
+	}
+	exact, _ := Analyze(addresses, 32)
+	sampled, err := Analyze(addresses, 32, WithSampling(0.1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sampled.Accesses > exact.Accesses/5 {
+		t.Fatal(fmt.Sprintf("Too many accesses analyzed: %d of %d", sampled.Accesses, exact.Accesses))
+	}
+	for _, blocks := range []int{2_000, 10_000, 18_000} {
+		if diff := math.Abs(exact.MissRatio(blocks) - sampled.MissRatio(blocks)); diff > 0.05 {
+			t.Fatal(fmt.Sprintf("Sampled miss ratio too far for %d blocks: %v instead of %v",
+				blocks, sampled.MissRatio(blocks), exact.MissRatio(blocks)))
+		}
+	}
+	for _, rate := range []float64{0, -1, 1.5} {
+		if _, err := NewAnalyzer(32, WithSampling(rate)); err == nil {
+			t.Fatal(fmt.Sprintf("Must fail with a sampling rate of %v", rate))
+		}
+	}
+	if _, err := NewAnalyzer(48); err == nil {
+		t.Fatal("Must fail with a block size not a power of 2")
+	}
+}
@@ -0,0 +1,122 @@
+package trace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Formats of the traces understood by ReadAddresses
+const (
+	FormatGimc = "gimc" // binary format of this package
+	FormatDin  = "din"  // Dinero IV format
+	FormatHex  = "hex"  // one hexadecimal address per line
+)
+
+// ReadAddresses reads the addresses of a trace in the given format
+func ReadAddresses(r io.Reader, format string) ([]uint32, error) {
+	switch format {
+	case FormatGimc:
+		return readGimc(r)
+	case FormatDin:
+		return ReadDin(r)
+	case FormatHex:
+		return ReadHex(r)
+	default:
+		return nil, errors.New(fmt.Sprintf("TRACE: Unknown format %s", format))
+	}
+}
+
+// readGimc reads the addresses of a trace in the binary format of this package
+func readGimc(r io.Reader) ([]uint32, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []uint32
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return addresses, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, record.Address)
+	}
+}
+
+// ReadHex reads a trace of one hexadecimal address per line, with or without the 0x prefix.
+// Empty lines and lines starting with # are ignored.
+func ReadHex(r io.Reader) ([]uint32, error) {
+	var addresses []uint32
+	err := readLines(r, func(line int, fields []string) error {
+		address, err := parseAddress(fields[0])
+		if err != nil {
+			return errors.New(fmt.Sprintf("TRACE: Line %d: %s", line, err))
+		}
+		addresses = append(addresses, address)
+		return nil
+	})
+	return addresses, err
+}
+
+// ReadDin reads a trace in the Dinero IV din format, one "label address [size]" per line with an hexadecimal address.
+// The reads (label 0), writes (1) and instruction fetches (2) are kept, the other labels (escape, flush) are ignored.
+func ReadDin(r io.Reader) ([]uint32, error) {
+	var addresses []uint32
+	err := readLines(r, func(line int, fields []string) error {
+		if len(fields) < 2 {
+			return errors.New(fmt.Sprintf("TRACE: Line %d: Missing address", line))
+		}
+		label, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return errors.New(fmt.Sprintf("TRACE: Line %d: Wrong label %s", line, fields[0]))
+		}
+		if label > 2 {
+			return nil
+		}
+		address, err := parseAddress(fields[1])
+		if err != nil {
+			return errors.New(fmt.Sprintf("TRACE: Line %d: %s", line, err))
+		}
+		addresses = append(addresses, address)
+		return nil
+	})
+	return addresses, err
+}
+
+// readLines calls parse with the fields of every line not empty nor commented
+func readLines(r io.Reader, parse func(line int, fields []string) error) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if err := parse(line, fields); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.New(fmt.Sprintf("TRACE: Cannot read: %s", err))
+	}
+	return nil
+}
+
+// parseAddress parses an hexadecimal address of at most 32 bits
+func parseAddress(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	address, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Wrong address %s", s))
+	}
+	if address > math.MaxUint32 {
+		return 0, errors.New(fmt.Sprintf("Address %s does not fit in 32 bits", s))
+	}
+	return uint32(address), nil
+}
@@ -0,0 +1,58 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadHex(t *testing.T) {
+	addresses, err := ReadHex(strings.NewReader("# comment\n0x10\n\nff\n0XABCDEF01\n"))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot read: %s", err))
+	}
+	if fmt.Sprint(addresses) != fmt.Sprint([]uint32{0x10, 0xff, 0xabcdef01}) {
+		t.Fatal(fmt.Sprintf("Wrong addresses %v", addresses))
+	}
+	if _, err := ReadHex(strings.NewReader("123456789\n")); err == nil {
+		t.Fatal("Addresses of more than 32 bits must be refused")
+	}
+	if _, err := ReadHex(strings.NewReader("xyz\n")); err == nil {
+		t.Fatal("Wrong addresses must be refused")
+	}
+}
+
+func TestReadDin(t *testing.T) {
+	din := "2 400100\n0 7ffc10 4\n1 7ffc14 4\n4 0\n3 1234\n"
+	addresses, err := ReadDin(strings.NewReader(din))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot read: %s", err))
+	}
+	if fmt.Sprint(addresses) != fmt.Sprint([]uint32{0x400100, 0x7ffc10, 0x7ffc14}) {
+		t.Fatal(fmt.Sprintf("Wrong addresses %v", addresses))
+	}
+	if _, err := ReadDin(strings.NewReader("0\n")); err == nil {
+		t.Fatal("Lines without address must be refused")
+	}
+}
+
+func TestReadAddressesGimc(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, address := range []uint32{64, 0, 128} {
+		_ = w.Write(Record{Address: address, Size: 8, Time: time.Now()})
+	}
+	_ = w.Flush()
+	addresses, err := ReadAddresses(&buf, FormatGimc)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot read: %s", err))
+	}
+	if fmt.Sprint(addresses) != fmt.Sprint([]uint32{64, 0, 128}) {
+		t.Fatal(fmt.Sprintf("Wrong addresses %v", addresses))
+	}
+	if _, err := ReadAddresses(&buf, "csv"); err == nil {
+		t.Fatal("Unknown formats must be refused")
+	}
+}
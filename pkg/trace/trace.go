@@ -0,0 +1,227 @@
+// Package trace reads and writes traces of cache accesses in a compact binary format.
+//
+// A trace starts with the magic "GIMCTRC1" and the time of the first record (varint, unix nanoseconds),
+// followed by the records. Each record is encoded as:
+//   - a flags byte: bit 0 for a write, bit 1 for a hit,
+//   - the size of the access (uvarint),
+//   - the difference with the address of the previous record (varint, the first one is relative to 0),
+//   - the nanoseconds elapsed since the previous record (uvarint).
+//
+// Sequential and close accesses thus take a few bytes each.
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Magic is the first bytes of a trace
+const Magic = "GIMCTRC1"
+
+const (
+	flagWrite = 1 << 0
+	flagHit   = 1 << 1
+)
+
+// Record is an access to a cache
+type Record struct {
+	Address uint32
+	Size    uint32
+	Write   bool
+	Hit     bool
+	Time    time.Time
+}
+
+// RecordWriter is implemented by the writers of traces
+type RecordWriter interface {
+	Write(r Record) error
+	Flush() error
+}
+
+// Writer writes a trace in an io.Writer. It is buffered, Flush must be called at the end.
+type Writer struct {
+	w       *bufio.Writer
+	started bool
+	address uint32    // address of the previous record
+	last    time.Time // time of the previous record
+	written int64     // number of bytes written
+	buf     [1 + 3*binary.MaxVarintLen64]byte
+}
+
+// NewWriter creates a writer of trace, the header is written with the first record
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Write appends the record to the trace
+func (w *Writer) Write(r Record) error {
+	if !w.started {
+		n := copy(w.buf[:], Magic)
+		n += binary.PutVarint(w.buf[n:], r.Time.UnixNano())
+		if err := w.write(w.buf[:n]); err != nil {
+			return err
+		}
+		w.started = true
+		w.last = r.Time
+	}
+	var flags byte
+	if r.Write {
+		flags |= flagWrite
+	}
+	if r.Hit {
+		flags |= flagHit
+	}
+	elapsed := r.Time.Sub(w.last)
+	if elapsed < 0 { // the clock went back
+		elapsed = 0
+	}
+	w.buf[0] = flags
+	n := 1
+	n += binary.PutUvarint(w.buf[n:], uint64(r.Size))
+	n += binary.PutVarint(w.buf[n:], int64(r.Address)-int64(w.address))
+	n += binary.PutUvarint(w.buf[n:], uint64(elapsed))
+	w.address = r.Address
+	w.last = w.last.Add(elapsed)
+	return w.write(w.buf[:n])
+}
+
+// Flush writes the buffered records in the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Written gives the number of bytes of the trace written so far, buffered ones included
+func (w *Writer) Written() int64 {
+	return w.written
+}
+
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+	if err != nil {
+		return errors.New(fmt.Sprintf("TRACE: Cannot write: %s", err))
+	}
+	return nil
+}
+
+// FileWriter writes a trace in files, rotating to a new file when the current one is bigger than a maximum size.
+// The files are named path, path.1, path.2... and each one is a complete trace.
+type FileWriter struct {
+	path     string
+	maxBytes int64
+	file     *os.File
+	writer   *Writer
+	files    int // number of files created
+}
+
+// NewFileWriter creates the file at path for the trace. If maxBytes is 0, there is no rotation.
+func NewFileWriter(path string, maxBytes int64) (*FileWriter, error) {
+	fw := &FileWriter{path: path, maxBytes: maxBytes}
+	if err := fw.open(); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+func (fw *FileWriter) open() error {
+	name := fw.path
+	if fw.files > 0 {
+		name = fmt.Sprintf("%s.%d", fw.path, fw.files)
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return errors.New(fmt.Sprintf("TRACE: Cannot create the file: %s", err))
+	}
+	fw.file = file
+	fw.writer = NewWriter(file)
+	fw.files++
+	return nil
+}
+
+// Write appends the record to the trace, rotating the file if needed
+func (fw *FileWriter) Write(r Record) error {
+	if fw.maxBytes > 0 && fw.writer.Written() >= fw.maxBytes {
+		if err := fw.Close(); err != nil {
+			return err
+		}
+		if err := fw.open(); err != nil {
+			return err
+		}
+	}
+	return fw.writer.Write(r)
+}
+
+// Flush writes the buffered records in the current file
+func (fw *FileWriter) Flush() error {
+	return fw.writer.Flush()
+}
+
+// Close flushes and closes the current file
+func (fw *FileWriter) Close() error {
+	if err := fw.writer.Flush(); err != nil {
+		return err
+	}
+	return fw.file.Close()
+}
+
+// Reader reads a trace written by Writer
+type Reader struct {
+	r       *bufio.Reader
+	address uint32
+	last    time.Time
+}
+
+// NewReader reads the header of the trace
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(Magic))
+	if _, err := io.ReadFull(br, header); err != nil || string(header) != Magic {
+		return nil, errors.New("TRACE: Not a gimc trace")
+	}
+	start, err := binary.ReadVarint(br)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("TRACE: Cannot read the header: %s", err))
+	}
+	return &Reader{r: br, last: time.Unix(0, start)}, nil
+}
+
+// Next gives the next record of the trace, io.EOF at the end of the trace
+func (r *Reader) Next() (Record, error) {
+	flags, err := r.r.ReadByte()
+	if err != nil {
+		return Record{}, err
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Record{}, corrupted(err)
+	}
+	delta, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return Record{}, corrupted(err)
+	}
+	elapsed, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Record{}, corrupted(err)
+	}
+	r.address = uint32(int64(r.address) + delta)
+	r.last = r.last.Add(time.Duration(elapsed))
+	return Record{
+		Address: r.address,
+		Size:    uint32(size),
+		Write:   flags&flagWrite != 0,
+		Hit:     flags&flagHit != 0,
+		Time:    r.last,
+	}, nil
+}
+
+func corrupted(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return errors.New(fmt.Sprintf("TRACE: Corrupted record: %s", err))
+}
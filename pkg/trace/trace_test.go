@@ -0,0 +1,107 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRecords(n int) []Record {
+	start := time.Unix(1_650_000_000, 123)
+	records := make([]Record, n)
+	for i := range records {
+		records[i] = Record{
+			Address: uint32((i * 7919) % 100_000 * 32),
+			Size:    32,
+			Write:   i%3 == 0,
+			Hit:     i%2 == 0,
+			Time:    start.Add(time.Duration(i*i) * time.Microsecond),
+		}
+	}
+	return records
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := testRecords(1000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatal(fmt.Sprintf("Cannot write: %s", err))
+		}
+	}
+	_ = w.Flush()
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot read the header: %s", err))
+	}
+	for i, expected := range records {
+		r, err := reader.Next()
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Cannot read record %d: %s", i, err))
+		}
+		if r.Address != expected.Address || r.Size != expected.Size || r.Write != expected.Write ||
+			r.Hit != expected.Hit || !r.Time.Equal(expected.Time) {
+			t.Fatal(fmt.Sprintf("Record %d is %+v, expected %+v", i, r, expected))
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatal("Must end with EOF")
+	}
+}
+
+func TestNotATrace(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("hello world"))); err == nil {
+		t.Fatal("Must refuse what is not a trace")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.bin")
+	fw, err := NewFileWriter(path, 1024)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create the file: %s", err))
+	}
+	records := testRecords(2000)
+	for _, r := range records {
+		if err := fw.Write(r); err != nil {
+			t.Fatal(fmt.Sprintf("Cannot write: %s", err))
+		}
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(fmt.Sprintf("Cannot close: %s", err))
+	}
+	if fw.files < 2 {
+		t.Fatal("Must have rotated")
+	}
+	// every file is a trace and all the records are there, in order
+	read := 0
+	for i := 0; i < fw.files; i++ {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Cannot open %s: %s", name, err))
+		}
+		reader, err := NewReader(file)
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Cannot read %s: %s", name, err))
+		}
+		for r, err := reader.Next(); err != io.EOF; r, err = reader.Next() {
+			if r.Address != records[read].Address {
+				t.Fatal(fmt.Sprintf("Wrong record %d", read))
+			}
+			read++
+		}
+		file.Close()
+	}
+	if read != len(records) {
+		t.Fatal(fmt.Sprintf("Read %d records, expected %d", read, len(records)))
+	}
+}
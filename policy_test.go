@@ -0,0 +1,534 @@
+package gimc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newMemSource creates an in-memory datasource of this size whose byte i is i*7 (see sourceBytes)
+func newMemSource(size int) *MemoryDatasource {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return NewMemoryDatasource(data)
+}
+
+// sourceBytes gives the n bytes at this address of a datasource created by newMemSource
+func sourceBytes(address uint32, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte((int(address) + i) * 7)
+	}
+	return data
+}
+
+var allPolicies = map[string]RePol{
+	"FIFO":   FIFO,
+	"LRU":    LRU,
+	"TwoQ":   TwoQ,
+	"S3FIFO": S3FIFO,
+	"LIRS":   LIRS,
+	"SRRIP":  SRRIP,
+	"BRRIP":  BRRIP,
+	"DRRIP":  DRRIP,
+}
+
+func TestPoliciesGet(t *testing.T) {
+	src := newMemSource(1 << 16)
+	for name, pol := range allPolicies {
+		t.Run(
+			name, func(t *testing.T) {
+				cache, err := CreateCache(4, 64, 8, 4, src, pol)
+				if err != nil {
+					t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+				}
+				checkRandomGets(t, cache, src)
+			},
+		)
+	}
+}
+
+func TestTinyLFUGet(t *testing.T) {
+	src := newMemSource(1 << 16)
+	for name, pol := range allPolicies {
+		t.Run(
+			name, func(t *testing.T) {
+				cache, err := CreateCache(4, 64, 8, 4, src, pol, WithTinyLFU())
+				if err != nil {
+					t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+				}
+				checkRandomGets(t, cache, src)
+			},
+		)
+	}
+}
+
+// checkRandomGets reads random addresses from the cache and compare them with the source
+func checkRandomGets(t *testing.T, cache *Cache, src *MemoryDatasource) {
+	for i := 0; i < 100_000; i++ {
+		address := uint32(rand.Intn(src.Len()/8)) * 8
+		if bytes.Compare(cache.Get(address), sourceBytes(address, 8)) != 0 {
+			t.Fatal(fmt.Sprintf("Wrong data at address %d", address))
+		}
+	}
+}
+
+// accessBlocks reads the blocks from first (included) to last (excluded) in a cache with blocks of 64 bytes
+func accessBlocks(cache *Cache, first, last uint32) {
+	for i := first; i < last; i++ {
+		cache.Get(i * 64)
+	}
+}
+
+func TestScanResistance(t *testing.T) {
+	src := newMemSource(1 << 16)
+	expected := map[RePol]uint64{
+		LRU:    4, // the whole working set has been flushed
+		TwoQ:   0,
+		S3FIFO: 0,
+	}
+	for pol, misses := range expected {
+		cache, err := CreateCache(1, 64, 8, 16, src, pol)
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+		}
+		// working set of 4 blocks, seen twice with a short scan between
+		accessBlocks(cache, 0, 4)
+		accessBlocks(cache, 100, 116)
+		accessBlocks(cache, 0, 4)
+		accessBlocks(cache, 0, 4)
+		// long scan of blocks used only once
+		accessBlocks(cache, 200, 264)
+		cache.ResetCounters()
+		accessBlocks(cache, 0, 4)
+		if _, m := cache.GetCounters(); m != misses {
+			t.Fatal(fmt.Sprintf("Policy %d: %d misses on the working set after a scan, expected %d", pol, m, misses))
+		}
+	}
+}
+
+func TestLoopResistance(t *testing.T) {
+	src := newMemSource(1 << 16)
+	lru, _ := CreateCache(1, 64, 8, 8, src, LRU)
+	lirs, _ := CreateCache(1, 64, 8, 8, src, LIRS)
+	// loop on 9 blocks in a set of 8 ways
+	for i := 0; i < 20; i++ {
+		accessBlocks(lru, 0, 9)
+		accessBlocks(lirs, 0, 9)
+	}
+	if hits, _ := lru.GetCounters(); hits != 0 {
+		t.Fatal(fmt.Sprintf("LRU must always miss on the loop, got %d hits", hits))
+	}
+	if hits, misses := lirs.GetCounters(); hits < misses {
+		t.Fatal(fmt.Sprintf("LIRS must mostly hit on the loop, got %d hits and %d misses", hits, misses))
+	}
+}
+
+func TestDRRIPDueling(t *testing.T) {
+	src := newMemSource(1 << 20)
+	cache, err := CreateCache(64, 64, 8, 4, src, DRRIP)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	// thrashing loop on 5 blocks per set, BRRIP keeps a part of it when SRRIP keeps nothing
+	for i := 0; i < 20; i++ {
+		accessBlocks(cache, 0, 64*5)
+	}
+	if !cache.duel.useB(duelFollower) {
+		t.Fatal(fmt.Sprintf("Followers must use BRRIP on a thrashing loop, psel is %d", cache.duel.psel))
+	}
+	// the followers keep a part of the loop as the BRRIP leaders, the SRRIP leaders keep nothing
+	followers := 0
+	for _, s := range cache.sets {
+		role := s.rePol.(*rrip).duelRole
+		switch {
+		case role == duelFollower:
+			followers++
+			if s.stats.hits == 0 {
+				t.Fatal(fmt.Sprintf("The follower set %d must hit as BRRIP", s.index))
+			}
+		case role == duelLeaderA && s.stats.hits > 0:
+			t.Fatal(fmt.Sprintf("The SRRIP leader set %d must not hit on the loop", s.index))
+		}
+	}
+	if followers == 0 {
+		t.Fatal("A small cache must have follower sets")
+	}
+}
+
+func TestDuelRoles(t *testing.T) {
+	for _, sets := range []uint16{4, 32, 64, 128, 1024} {
+		d := newDuel(sets)
+		roles := make(map[int]int)
+		for i := uint32(0); i < uint32(sets); i++ {
+			roles[d.role(i)]++
+		}
+		if roles[duelLeaderA] == 0 || roles[duelLeaderA] != roles[duelLeaderB] || roles[duelLeaderA] > duelLeaders {
+			t.Fatal(fmt.Sprintf("Wrong leaders for %d sets: %v", sets, roles))
+		}
+		if roles[duelFollower] < int(sets)/2 {
+			t.Fatal(fmt.Sprintf("Half of the %d sets at least must follow: %v", sets, roles))
+		}
+	}
+}
+
+func TestTinyLFUAdmission(t *testing.T) {
+	src := newMemSource(1 << 16)
+	cache, err := CreateCache(1, 64, 8, 4, src, LRU, WithTinyLFU())
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	// frequent working set, then a scan of blocks used once
+	for i := 0; i < 5; i++ {
+		accessBlocks(cache, 0, 4)
+	}
+	accessBlocks(cache, 100, 132)
+	cache.ResetCounters()
+	accessBlocks(cache, 0, 4)
+	if _, misses := cache.GetCounters(); misses != 0 {
+		t.Fatal(fmt.Sprintf("The scan must not be admitted, got %d misses on the working set", misses))
+	}
+}
+
+// createPolicyCache creates a cache of one set of 4 ways with the policy, the trace being given to OPT
+func createPolicyCache(t *testing.T, src Datasource, pol RePol, trace []uint32, opts ...Option) *Cache {
+	if pol == OPT {
+		opts = append(opts, WithTrace(trace))
+	}
+	cache, err := CreateCache(1, 64, 8, 4, src, pol, opts...)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	return cache
+}
+
+func TestTinyLFURejection(t *testing.T) {
+	src := newMemSource(1 << 16)
+	// 6 blocks on 4 ways so that the policies have ghost or non-resident tags, then a block used once
+	var trace []uint32
+	for i := 0; i < 5; i++ {
+		for block := uint32(0); block < 6; block++ {
+			trace = append(trace, block*64)
+		}
+	}
+	trace = append(trace, 100*64)
+	policies := map[string]RePol{"OPT": OPT}
+	for name, pol := range allPolicies {
+		policies[name] = pol
+	}
+	for name, pol := range policies {
+		t.Run(
+			name, func(t *testing.T) {
+				cache := createPolicyCache(t, src, pol, trace, WithTinyLFU())
+				for _, address := range trace[:len(trace)-1] {
+					cache.Get(address)
+				}
+				s := cache.sets[0]
+				before := s.rePol.describe()
+				var psel uint16
+				if cache.duel != nil {
+					psel = cache.duel.psel
+				}
+				if bytes.Compare(cache.Get(100*64), sourceBytes(100*64, 8)) != 0 {
+					t.Fatal("Wrong data for the rejected block")
+				}
+				if _, ok := s.ways[100*64>>(ADDRESSLENGTH-cache.tagSize)]; ok {
+					t.Fatal("The block used once must be rejected")
+				}
+				if after := s.rePol.describe(); !reflect.DeepEqual(before, after) {
+					t.Fatal(fmt.Sprintf("A rejection must not change the policy, %v became %v", before, after))
+				}
+				if cache.duel != nil && cache.duel.psel != psel {
+					t.Fatal(fmt.Sprintf("A rejection must not be counted by the duel, psel %d became %d", psel, cache.duel.psel))
+				}
+			},
+		)
+	}
+}
+
+func TestVictim(t *testing.T) {
+	src := newMemSource(1 << 16)
+	trace := make([]uint32, 10_000)
+	for i := range trace {
+		trace[i] = uint32(rand.Intn(10)) * 64
+	}
+	policies := map[string]RePol{"OPT": OPT}
+	for name, pol := range allPolicies {
+		policies[name] = pol
+	}
+	for name, pol := range policies {
+		t.Run(
+			name, func(t *testing.T) {
+				cache := createPolicyCache(t, src, pol, trace)
+				s := cache.sets[0]
+				for _, address := range trace {
+					tag := address >> (ADDRESSLENGTH - cache.tagSize)
+					_, resident := s.ways[tag]
+					if resident || len(s.ways) < int(s.maxWays) {
+						cache.Get(address)
+						continue
+					}
+					victim := s.rePol.victim()
+					cache.Get(address)
+					if _, ok := s.ways[victim]; ok {
+						t.Fatal(fmt.Sprintf("The victim %d announced is still resident", victim))
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestOptimalHitRate(t *testing.T) {
+	// textbook example, 9 misses with 3 frames
+	pages := []uint32{7, 0, 1, 2, 0, 3, 0, 4, 2, 3, 0, 3, 2, 1, 2, 0, 1, 7, 0, 1}
+	trace := make([]uint32, len(pages))
+	for i, p := range pages {
+		trace[i] = p * 64
+	}
+	_, hits, misses, err := OptimalHitRate(1, 64, 3, trace)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot simulate: %s", err))
+	}
+	if hits != 11 || misses != 9 {
+		t.Fatal(fmt.Sprintf("Expected 11 hits and 9 misses, got %d and %d", hits, misses))
+	}
+}
+
+func TestOPTCache(t *testing.T) {
+	src := newMemSource(1 << 16)
+	trace := make([]uint32, 50_000)
+	for i := range trace {
+		trace[i] = uint32(rand.Intn(src.Len()/8)) * 8
+	}
+	cache, err := CreateCache(4, 64, 8, 4, src, OPT, WithTrace(trace))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	lru, _ := CreateCache(4, 64, 8, 4, src, LRU)
+	for _, address := range trace {
+		if bytes.Compare(cache.Get(address), sourceBytes(address, 8)) != 0 {
+			t.Fatal(fmt.Sprintf("Wrong data at address %d", address))
+		}
+		lru.Get(address)
+	}
+	hits, _ := cache.GetCounters()
+	_, optHits, _, _ := OptimalHitRate(4, 64, 4, trace)
+	if hits != optHits {
+		t.Fatal(fmt.Sprintf("The cache got %d hits but the simulation %d", hits, optHits))
+	}
+	if lruHits, _ := lru.GetCounters(); lruHits > hits {
+		t.Fatal(fmt.Sprintf("LRU cannot be better than OPT: %d > %d", lruHits, hits))
+	}
+	if _, err := CreateCache(4, 64, 8, 4, src, OPT); err == nil {
+		t.Fatal("OPT without trace must be refused")
+	}
+}
+
+func TestSetDueling(t *testing.T) {
+	src := newMemSource(1 << 18)
+	cache, err := CreateCache(256, 64, 8, 8, src, LRU, WithSetDueling(LIRS))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	lru, _ := CreateCache(256, 64, 8, 8, src, LRU)
+	// loop on 9 blocks per set, LIRS leaders miss less than LRU leaders
+	for i := 0; i < 20; i++ {
+		accessBlocks(cache, 0, 256*9)
+		accessBlocks(lru, 0, 256*9)
+	}
+	if pol := cache.sets[1].pol; pol != LIRS {
+		t.Fatal(fmt.Sprintf("Followers must use LIRS on a loop, got %d", pol))
+	}
+	hits, _ := cache.GetCounters()
+	if lruHits, _ := lru.GetCounters(); hits <= lruHits {
+		t.Fatal(fmt.Sprintf("Set dueling must beat LRU on a loop: %d <= %d", hits, lruHits))
+	}
+	checkRandomGets(t, cache, src)
+	if _, err := CreateCache(256, 64, 8, 8, src, LRU, WithSetDueling(DRRIP)); err == nil {
+		t.Fatal("DRRIP cannot be used with set dueling")
+	}
+	if _, err := CreateCache(256, 64, 8, 8, src, LRU, WithSetDueling(OPT)); err == nil {
+		t.Fatal("OPT without trace must be refused as rival")
+	}
+}
+
+func TestSetDuelingSwitch(t *testing.T) {
+	src := newMemSource(1 << 16)
+	cache, err := CreateCache(16, 64, 8, 4, src, LRU, WithSetDueling(S3FIFO))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	s := cache.sets[1]
+	if s.duelRole != duelFollower {
+		t.Fatal("The set 1 must be a follower")
+	}
+	// tags of the lines of a policy, sorted
+	tags := func(pol repol) []uint32 {
+		var tags []uint32
+		for _, line := range pol.describe() {
+			tags = append(tags, line.tag)
+		}
+		sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+		return tags
+	}
+	for i := 0; i < 1000; i++ {
+		// psel hovering around its midpoint
+		cache.duel.psel = pselMax/2 + uint16(i%2)
+		used, idle := s.rePol, s.idle
+		cache.Get(uint32(rand.Intn(12))*16*64 + 64) // blocks of the set 1
+		if s.pol == LRU == (i%2 == 1) {
+			t.Fatal(fmt.Sprintf("Wrong policy %s with psel %d", s.pol, cache.duel.psel))
+		}
+		if i > 0 && (s.rePol != idle || s.idle != used) {
+			t.Fatal("A switch must reuse the state of the idle policy")
+		}
+		if !reflect.DeepEqual(tags(s.rePol), tags(s.idle)) || len(tags(s.rePol)) != len(s.ways) {
+			t.Fatal(fmt.Sprintf("Both policies must know the resident tags, %v and %v", tags(s.rePol), tags(s.idle)))
+		}
+	}
+}
+
+func BenchmarkLRU(b *testing.B) {
+	for _, ways := range []uint16{16, 64} {
+		b.Run(
+			fmt.Sprintf("%d ways", ways), func(b *testing.B) {
+				benchmarkRePol(b, newLRU(), ways)
+			},
+		)
+	}
+}
+
+// benchmarkRePol drives the replacement policy of a set of maxWays ways like a set would do,
+// on a working set twice as big as the set (about half hits and half misses)
+func benchmarkRePol(b *testing.B, pol repol, maxWays uint16) {
+	tags := make([]uint32, 4096)
+	for i := range tags {
+		tags[i] = uint32(rand.Intn(2 * int(maxWays)))
+	}
+	resident := make(map[uint32]bool)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tag := tags[i%len(tags)]
+		if resident[tag] {
+			pol.hit(tag)
+			continue
+		}
+		if len(resident) >= int(maxWays) {
+			delete(resident, pol.toReplace())
+		}
+		resident[tag] = true
+		pol.miss(tag)
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	src := newMemSource(1 << 16)
+	cache, err := CreateCache(4, 64, 8, 4, src, FIFO, WithSetPolicy([]uint16{1, 3}, LIRS, 8))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	for i, s := range cache.sets {
+		if i%2 == 1 && (s.pol != LIRS || s.maxWays != 8) {
+			t.Fatal(fmt.Sprintf("Set %d must use its own policy", i))
+		}
+		if i%2 == 0 && (s.pol != FIFO || s.maxWays != 4) {
+			t.Fatal(fmt.Sprintf("Set %d must use the policy of the cache", i))
+		}
+	}
+	checkRandomGets(t, cache, src)
+	if _, err := CreateCache(4, 64, 8, 4, src, FIFO, WithSetPolicy([]uint16{4}, LRU, 0)); err == nil {
+		t.Fatal("Set index out of range must be refused")
+	}
+}
+
+func TestPolicyOptionsReused(t *testing.T) {
+	src := newMemSource(1 << 16)
+	opts := []Option{WithSetPolicy([]uint16{0}, LRU, 0), WithRegionPolicy(0, 512, LRU, 0)}
+	for _, ways := range []uint16{1, 16} {
+		cache, err := CreateCache(4, 64, 8, ways, src, FIFO, opts...)
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+		}
+		if cache.sets[0].maxWays != ways || cache.regions[0].sets[0].maxWays != ways {
+			t.Fatal(fmt.Sprintf("The sets must keep the %d ways of the cache, got %d and %d",
+				ways, cache.sets[0].maxWays, cache.regions[0].sets[0].maxWays))
+		}
+	}
+}
+
+func TestRegionPolicy(t *testing.T) {
+	src := newMemSource(1 << 16)
+	// header of 8 blocks in its own sets
+	cache, err := CreateCache(4, 64, 8, 2, src, FIFO, WithRegionPolicy(0, 512, LRU, 2))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	accessBlocks(cache, 0, 8)
+	// scan of the data, does not evict the header
+	accessBlocks(cache, 8, 200)
+	cache.ResetCounters()
+	accessBlocks(cache, 0, 8)
+	if _, misses := cache.GetCounters(); misses != 0 {
+		t.Fatal(fmt.Sprintf("The header must stay in its region, got %d misses", misses))
+	}
+	checkRandomGets(t, cache, src)
+	if _, err := CreateCache(4, 64, 8, 2, src, FIFO, WithRegionPolicy(0, 512, LRU, 2), WithRegionPolicy(256, 1024, LRU, 2)); err == nil {
+		t.Fatal("Overlapping regions must be refused")
+	}
+	if _, err := CreateCache(4, 64, 8, 2, src, FIFO, WithRegionPolicy(0, 500, LRU, 2)); err == nil {
+		t.Fatal("A region not aligned on the blocks must be refused")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	src := newMemSource(1 << 16)
+	trace := make([]uint32, 20_000)
+	for i := range trace {
+		trace[i] = uint32(rand.Intn(src.Len()/8)) * 8
+	}
+	policies := map[string]RePol{"OPT": OPT}
+	for name, pol := range allPolicies {
+		policies[name] = pol
+	}
+	for name, pol := range policies {
+		cache, err := CreateCache(4, 64, 8, 8, src, pol, WithTrace(trace))
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+		}
+		for i, address := range trace {
+			if bytes.Compare(cache.Get(address), sourceBytes(address, 8)) != 0 {
+				t.Fatal(fmt.Sprintf("%s: wrong data at address %d", name, address))
+			}
+			if i%7 == 0 {
+				cache.Invalidate(trace[i/2])
+			}
+		}
+		snapshot := cache.Snapshot()
+		for _, set := range snapshot.Sets {
+			for rank, line := range set.Lines {
+				if line.Rank != rank || len(set.Lines) != set.Occupancy {
+					t.Fatal(fmt.Sprintf("%s: the policy must forget the invalidated lines: %+v", name, set))
+				}
+			}
+		}
+		line := snapshot.Sets[0].Lines[0]
+		if !cache.Invalidate(line.Address) || cache.Invalidate(line.Address) {
+			t.Fatal(fmt.Sprintf("%s: Invalidate must tell if the block was cached", name))
+		}
+		cache.Flush()
+		if lines := cache.Snapshot().Lines; lines != 0 {
+			t.Fatal(fmt.Sprintf("%s: %d lines left after the flush", name, lines))
+		}
+		cache.Get(line.Address)
+		if _, misses := cache.GetCounters(); misses == 0 || cache.Snapshot().Lines != 1 {
+			t.Fatal(fmt.Sprintf("%s: the cache must be usable after the flush", name))
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package gimc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ag0st/gimc/pkg/trace"
+)
+
+// Recorder wraps a cache and records its accesses in a trace (see package trace), to replay them offline.
+type Recorder struct {
+	cache    *Cache
+	mu       sync.Mutex // serializes the writes in the trace
+	w        trace.RecordWriter
+	sampling uint32 // 1 out of sampling blocks is recorded
+	err      error  // first error of the writer, the recording stops after it
+}
+
+// NewRecorder creates a recorder of the accesses made with its Get method.
+// With a sampling greater than 1, only the accesses to 1 out of sampling blocks (chosen by a hash of their address)
+// are recorded. All the accesses to a recorded block are kept, so the trace keeps the reuses of the blocks.
+func NewRecorder(cache *Cache, w trace.RecordWriter, sampling uint32) *Recorder {
+	if sampling == 0 {
+		sampling = 1
+	}
+	return &Recorder{
+		cache:    cache,
+		w:        w,
+		sampling: sampling,
+	}
+}
+
+// Get data at this address using the cache and records the access
+func (r *Recorder) Get(address uint32) []byte {
+	data, hit, err := r.cache.get(address)
+	if err != nil {
+		r.cache.logReadError(address, err)
+	}
+	if r.sampling > 1 && hash32(address & ^r.cache.offsetMask, 0)%r.sampling != 0 {
+		return data
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.w.Write(trace.Record{
+			Address: address,
+			Size:    uint32(r.cache.dataSize),
+			Hit:     hit,
+			Time:    time.Now(),
+		})
+	}
+	return data
+}
+
+// Flush writes the buffered records, it gives the first error met by the recording if any
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
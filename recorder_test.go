@@ -0,0 +1,64 @@
+package gimc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/ag0st/gimc/pkg/trace"
+)
+
+func TestRecorder(t *testing.T) {
+	src := newMemSource(1 << 16)
+	cache, _ := CreateCache(4, 64, 8, 2, src, LRU)
+	var buf bytes.Buffer
+	recorder := NewRecorder(cache, trace.NewWriter(&buf), 1)
+	addresses := []uint32{0, 8, 1024, 0, 64, 4096}
+	for _, address := range addresses {
+		if bytes.Compare(recorder.Get(address), sourceBytes(address, 8)) != 0 {
+			t.Fatal("Recorder must give the data of the cache")
+		}
+	}
+	if err := recorder.Flush(); err != nil {
+		t.Fatal(fmt.Sprintf("Cannot flush: %s", err))
+	}
+	reader, err := trace.NewReader(&buf)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot read the trace: %s", err))
+	}
+	hits := []bool{false, true, false, true, false, false}
+	for i, address := range addresses {
+		r, err := reader.Next()
+		if err != nil || r.Address != address || r.Hit != hits[i] || r.Size != 8 {
+			t.Fatal(fmt.Sprintf("Wrong record %d: %+v", i, r))
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatal("Must end with EOF")
+	}
+}
+
+func TestRecorderSampling(t *testing.T) {
+	src := newMemSource(1 << 16)
+	cache, _ := CreateCache(4, 64, 8, 2, src, LRU)
+	var buf bytes.Buffer
+	recorder := NewRecorder(cache, trace.NewWriter(&buf), 4)
+	for i := uint32(0); i < 1024; i++ {
+		recorder.Get(i * 64)
+		recorder.Get(i * 64) // same block, recorded or not with the first access
+	}
+	_ = recorder.Flush()
+	reader, _ := trace.NewReader(&buf)
+	count := 0
+	for r, err := reader.Next(); err != io.EOF; r, err = reader.Next() {
+		second, _ := reader.Next()
+		if second.Address != r.Address {
+			t.Fatal("All the accesses of a sampled block must be recorded")
+		}
+		count += 2
+	}
+	if count < 256 || count > 768 {
+		t.Fatal(fmt.Sprintf("About a quarter of the accesses must be recorded, got %d of 2048", count))
+	}
+}
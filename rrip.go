@@ -0,0 +1,135 @@
+package gimc
+
+import "sort"
+
+const (
+	rripBits      = 2                      // M, number of bits of a re-reference prediction value (RRPV)
+	rripMax       = uint8(1<<rripBits - 1) // RRPV of a line predicted to be re-referenced in a distant future
+	brripEpsilon  = 32                     // BRRIP inserts with a long interval once every brripEpsilon insertions
+	rripNoFreeWay = -1
+)
+
+// Variants of RRIP
+const (
+	srrip = iota // Static: insert with a long re-reference interval
+	brrip        // Bimodal: insert with a distant re-reference interval, rarely with a long one
+	drrip        // Dynamic: set dueling between SRRIP and BRRIP
+)
+
+// rrip is the structure used to implement and represent the Re-Reference Interval Prediction replacement policies.
+// Each way stores a M-bit RRPV, 0 meaning the line is expected to be re-referenced soon. A hit resets the RRPV
+// of the line. The victim is the first way with the maximum RRPV, all RRPV being incremented until one is found.
+type rrip struct {
+	tags     []uint32       // tag of each way
+	rrpv     []uint8        // RRPV of each way
+	ways     map[uint32]int // way of each tag
+	freeWay  int            // way freed by the last replacement, rripNoFreeWay if none
+	variant  int
+	inserts  uint32 // number of bimodal insertions, used for the BRRIP throttle
+	duel     *duel  // shared between the sets for DRRIP
+	duelRole int
+}
+
+// newRRIP creates a RRIP policy of the given variant, duel and index are only used by DRRIP
+func newRRIP(maxWays uint16, variant int, duel *duel, index uint32) *rrip {
+	r := &rrip{
+		tags:    make([]uint32, 0, maxWays),
+		rrpv:    make([]uint8, 0, maxWays),
+		ways:    make(map[uint32]int, maxWays),
+		freeWay: rripNoFreeWay,
+		variant: variant,
+	}
+	if variant == drrip {
+		r.duel = duel
+		r.duelRole = duel.role(index)
+	}
+	return r
+}
+
+func (r *rrip) toReplace() uint32 {
+	for {
+		for i, v := range r.rrpv {
+			if v == rripMax {
+				victim := r.tags[i]
+				delete(r.ways, victim)
+				r.freeWay = i
+				return victim
+			}
+		}
+		// no distant line, age them all
+		for i := range r.rrpv {
+			r.rrpv[i]++
+		}
+	}
+}
+
+// victim gives the first way with the highest RRPV, the one toReplace finds after aging all the lines
+func (r *rrip) victim() uint32 {
+	way := -1
+	for i, v := range r.rrpv {
+		if way < 0 || v > r.rrpv[way] {
+			way = i
+		}
+	}
+	return r.tags[way]
+}
+
+func (r *rrip) hit(tag uint32) {
+	r.rrpv[r.ways[tag]] = 0
+}
+
+func (r *rrip) miss(tag uint32) {
+	if r.duel != nil {
+		r.duel.miss(r.duelRole)
+	}
+	rrpv := r.insertionRRPV()
+	if r.freeWay == rripNoFreeWay {
+		r.ways[tag] = len(r.tags)
+		r.tags = append(r.tags, tag)
+		r.rrpv = append(r.rrpv, rrpv)
+		return
+	}
+	r.ways[tag] = r.freeWay
+	r.tags[r.freeWay] = tag
+	r.rrpv[r.freeWay] = rrpv
+	r.freeWay = rripNoFreeWay
+}
+
+// insertionRRPV gives the RRPV of a new line regarding the variant
+func (r *rrip) insertionRRPV() uint8 {
+	bimodal := r.variant == brrip || (r.variant == drrip && r.duel.useB(r.duelRole))
+	if !bimodal {
+		return rripMax - 1
+	}
+	r.inserts++
+	if r.inserts%brripEpsilon == 0 {
+		return rripMax - 1
+	}
+	return rripMax
+}
+
+// describe gives the tags with their RRPV, from the most distant predicted re-reference, as toReplace searches them
+func (r *rrip) describe() []policyLine {
+	lines := make([]policyLine, 0, len(r.ways))
+	for i, tag := range r.tags {
+		if way, ok := r.ways[tag]; ok && way == i { // the freed way is not resident anymore
+			lines = append(lines, policyLine{tag: tag, value: int(r.rrpv[i])})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].value > lines[j].value })
+	return lines
+}
+
+// remove forgets an invalidated tag, the last way takes its place
+func (r *rrip) remove(tag uint32) {
+	i, ok := r.ways[tag]
+	if !ok {
+		return
+	}
+	last := len(r.tags) - 1
+	r.tags[i], r.rrpv[i] = r.tags[last], r.rrpv[last]
+	r.ways[r.tags[i]] = i
+	delete(r.ways, tag)
+	r.tags = r.tags[:last]
+	r.rrpv = r.rrpv[:last]
+}
@@ -0,0 +1,110 @@
+package gimc
+
+const s3fifoMaxFreq = 3 // frequencies are stored on 2 bits
+
+// s3fifo is the structure used to implement and represent the S3-FIFO replacement policy.
+// New tags enter the small FIFO, tags accessed again before leaving it are moved to the main FIFO,
+// others are remembered in the ghost FIFO (tags only, no data). A tag found in the ghost FIFO
+// on a miss goes directly into the main FIFO. One-hit wonders of a scan thus never reach the main FIFO.
+type s3fifo struct {
+	small, main, ghost *tagList // the frequency of a tag is stored in the node value
+	smallSize          int      // target size of the small FIFO
+	ghostSize          int      // maximum size of the ghost FIFO
+}
+
+// newS3FIFO creates a S3-FIFO policy for a set of maxWays ways, using 10% of the ways for the small FIFO.
+func newS3FIFO(maxWays uint16) *s3fifo {
+	return &s3fifo{
+		small:     newTagList(),
+		main:      newTagList(),
+		ghost:     newTagList(),
+		smallSize: maxInt(1, int(maxWays)/10),
+		ghostSize: int(maxWays),
+	}
+}
+
+func (s *s3fifo) toReplace() uint32 {
+	for {
+		if s.small.len() > 0 && (s.small.len() >= s.smallSize || s.main.len() == 0) {
+			n := s.small.popFront()
+			if n.val > 0 {
+				// accessed again since insertion, keep it
+				s.main.pushBack(n.tag)
+				continue
+			}
+			s.ghost.pushBack(n.tag)
+			if s.ghost.len() > s.ghostSize {
+				s.ghost.popFront()
+			}
+			return n.tag
+		}
+		n := s.main.front()
+		if n.val > 0 {
+			// reinsert with a lower frequency
+			n.val--
+			s.main.moveToBack(n)
+			continue
+		}
+		s.main.popFront()
+		return n.tag
+	}
+}
+
+// victim gives the tag toReplace would return, without moving the tags between the FIFOs.
+// The tags of the small FIFO accessed again are skipped as they would go to the main FIFO with a frequency of 0,
+// then the victim is the first tag of the main FIFO with the lowest frequency, toReplace decrementing them in turn.
+func (s *s3fifo) victim() uint32 {
+	small, main := s.small.len(), s.main.len()
+	n := s.small.front()
+	var promoted *tagNode // first tag of the small FIFO that would be moved to the main FIFO
+	for ; small > 0 && (small >= s.smallSize || main == 0); n = s.small.next(n) {
+		if n.val == 0 {
+			return n.tag
+		}
+		if promoted == nil {
+			promoted = n
+		}
+		small--
+		main++
+	}
+	var lowest *tagNode
+	for n := s.main.front(); n != nil; n = s.main.next(n) {
+		if lowest == nil || n.val < lowest.val {
+			lowest = n
+		}
+	}
+	if lowest == nil || (promoted != nil && lowest.val > 0) {
+		return promoted.tag
+	}
+	return lowest.tag
+}
+
+func (s *s3fifo) hit(tag uint32) {
+	n := s.small.get(tag)
+	if n == nil {
+		n = s.main.get(tag)
+	}
+	if n != nil && n.val < s3fifoMaxFreq {
+		n.val++
+	}
+}
+
+func (s *s3fifo) miss(tag uint32) {
+	if s.ghost.remove(tag) {
+		s.main.pushBack(tag)
+	} else {
+		s.small.pushBack(tag)
+	}
+}
+
+// describe gives the tags of the small FIFO then of the main FIFO with their frequency, each from the oldest one
+func (s *s3fifo) describe() []policyLine {
+	return describeList(s.main, "main", describeList(s.small, "small", nil))
+}
+
+// remove forgets an invalidated tag, it is not remembered in the ghost FIFO
+func (s *s3fifo) remove(tag uint32) {
+	if !s.small.remove(tag) {
+		s.main.remove(tag)
+	}
+}
@@ -1,9 +1,13 @@
 package gimc
 
 import (
-	"github.com/ag0st/gimc/pkg/heap"
+	"context"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -14,9 +18,16 @@ const (
 )
 
 type set struct {
-	ways  map[uint32][]byte // First byte in the array are tags
-	cache *Cache            // Pointer to the cache used for shared options
-	rePol repol
+	stats    counters          // first for the alignment of the atomic counters
+	ways     map[uint32][]byte // First byte in the array are tags
+	cache    *Cache            // Pointer to the cache used for shared options
+	rePol    repol
+	pol      RePol  // replacement policy currently used by rePol
+	idle     repol  // for a follower of the set dueling, state of the policy not used, kept up to date to switch to it
+	maxWays  uint16 // number of maximum ways of this set
+	index    uint32 // index of the set in the cache
+	duels    bool   // tells if the set takes part in the set dueling of the cache
+	duelRole int    // role of the set in the set dueling
 }
 
 // createSet create a logical set of a cache
@@ -24,66 +35,245 @@ type set struct {
 // waysMax number of ways for the set, min 1
 // dataSize size of the data
 // blockSize is the size (number of bytes) to store in a cache entry, must be a power of 2
-func createSet(cache *Cache) *set {
+// index is the index of the set in the cache
+// config gives the replacement policy and the number of ways of the set
+// duels tells if the set takes part in the set dueling of the cache
+func createSet(cache *Cache, index uint32, config setConfig, duels bool) *set {
 	s := &set{
-		ways:  make(map[uint32][]byte),
-		cache: cache,
+		ways:     make(map[uint32][]byte),
+		cache:    cache,
+		index:    index,
+		pol:      config.pol,
+		maxWays:  config.ways,
+		duelRole: duelFollower,
 	}
-	switch cache.repol {
+	if duels {
+		s.duels = true
+		s.duelRole = cache.duel.role(index)
+		s.pol = cache.duelPolicy(s.duelRole)
+	}
+	s.rePol = s.newRePol(s.pol)
+	if s.duels && s.duelRole == duelFollower {
+		s.idle = s.newRePol(s.idlePolicy())
+	}
+	s.stats.setPolicy(s.pol)
+	return s
+}
+
+// idlePolicy gives the policy of the duel not used by the set
+func (s *set) idlePolicy() RePol {
+	if s.pol == s.cache.repol {
+		return *s.cache.rival
+	}
+	return s.cache.repol
+}
+
+// newRePol creates the structure of the replacement policy pol for this set
+func (s *set) newRePol(pol RePol) repol {
+	switch pol {
 	case FIFO:
-		s.rePol = &fifo{}
+		return &fifo{}
 	case LRU:
-		s.rePol = &lru{
-			heap:    heap.NewHeap(int(cache.maxWays)),
-			lclock:  0,
-			maxSize: s.cache.maxWays,
-		}
-	default:
-		log.Fatalln("Not known replacement policy.")
+		return newLRU()
+	case TwoQ:
+		return newTwoQ(s.maxWays)
+	case S3FIFO:
+		return newS3FIFO(s.maxWays)
+	case LIRS:
+		return newLIRS(s.maxWays)
+	case SRRIP:
+		return newRRIP(s.maxWays, srrip, nil, s.index)
+	case BRRIP:
+		return newRRIP(s.maxWays, brrip, nil, s.index)
+	case DRRIP:
+		return newRRIP(s.maxWays, drrip, s.cache.duel, s.index)
+	case OPT:
+		return newOPT(s.cache.trace, s.maxWays, s.cache.logger)
+	default: // the policies are checked at the creation of the cache
+		s.log(slog.LevelError, "unknown replacement policy, FIFO used instead", slog.Int("unknown", int(pol)))
+		return &fifo{}
 	}
-	return s
 }
 
-func (s *set) get(address uint32) []byte {
+// switchRePol makes a follower of the set dueling use its idle policy, which has seen all the accesses of the set
+func (s *set) switchRePol() {
+	s.pol = s.idlePolicy()
+	s.rePol, s.idle = s.idle, s.rePol
+	s.stats.setPolicy(s.pol)
+}
+
+// get gives the data at this address and tells if it was a hit.
+// If the block cannot be read from the source, the error is returned and the block is not cached.
+func (s *set) get(address uint32) ([]byte, bool, error) {
 	// get the tag
 	tag := address >> (ADDRESSLENGTH - s.cache.tagSize)
 	offset := address & s.cache.offsetMask
+	block := address & ^s.cache.offsetMask
+	if s.cache.admission != nil {
+		s.cache.admission.record(block)
+	}
+	if s.duels && s.duelRole == duelFollower {
+		if pol := s.cache.duelPolicy(duelFollower); pol != s.pol {
+			if s.cache.debug {
+				s.log(slog.LevelDebug, "switch", slog.String("to", pol.String()))
+			}
+			s.switchRePol()
+		}
+	}
 	var val []byte
 	val, ok := s.ways[tag]
 	if !ok {
-		s.cache.missCount++
+		if s.cache.instrumented != nil {
+			atomic.AddInt64(&s.cache.instrumented.misses.inFlight, 1)
+			defer s.cache.instrumented.misses.record(time.Now(), 0, nil)
+		}
+		atomic.AddUint64(&s.stats.misses, 1)
+		if s.cache.debug {
+			s.log(slog.LevelDebug, "miss", slog.Uint64("tag", uint64(tag)), addressAttr(address))
+		}
+		s.cache.onMiss(address)
+		if s.duels {
+			s.cache.duel.miss(s.duelRole)
+		}
 		// replacement policy
-		s.replace(tag, address & ^s.cache.offsetMask)
-		val = s.ways[tag]
-		s.rePol.miss(tag)
+		var err error
+		if val, err = s.replace(tag, block); err != nil {
+			return nil, false, err
+		}
 	} else {
-		s.cache.hitCount++
+		atomic.AddUint64(&s.stats.hits, 1)
+		if s.cache.debug {
+			s.log(slog.LevelDebug, "hit", slog.Uint64("tag", uint64(tag)), addressAttr(address))
+		}
+		s.cache.onHit(address)
 		s.rePol.hit(tag)
+		if s.idle != nil {
+			s.idle.hit(tag)
+		}
+		if s.cache.tracker != nil {
+			s.cache.tracker.hit(block)
+		}
+	}
+	if s.cache.source == nil { // only tags are kept
+		return nil, ok, nil
 	}
-	return val[offset+1 : uint32(s.cache.dataSize)+offset+1] // first byte are tags
+	return val[offset+1 : uint32(s.cache.dataSize)+offset+1], ok, nil // first byte are tags
 }
 
-func (s *set) replace(tag, address uint32) {
-	if len(s.ways) >= int(s.cache.maxWays) { // all ways are full, remove the oldest one
+// replace loads the block at this address in the set, replacing a way if all are full, and returns its data.
+// With an admission filter, the block may be returned without being put in the set.
+func (s *set) replace(tag, address uint32) ([]byte, error) {
+	val, err := s.load(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.ways) >= int(s.maxWays) { // all ways are full, remove the oldest one
+		if s.cache.admission != nil {
+			if victim := s.rePol.victim(); !s.cache.admission.admit(address, s.blockAddress(victim)) {
+				// the victim is more valuable, do not cache the block and leave the policy untouched
+				if s.cache.debug {
+					s.log(slog.LevelDebug, "reject", addressAttr(address), slog.Uint64("victim", uint64(victim)))
+				}
+				return val, nil
+			}
+		}
 		// Get the tag to replace
 		toReplace := s.rePol.toReplace()
+		if s.idle != nil {
+			s.idle.remove(toReplace)
+		}
+		evicted := s.ways[toReplace]
 		s.ways[toReplace] = nil   // delete array
 		delete(s.ways, toReplace) // delete entry
+		atomic.AddUint64(&s.stats.evictions, 1)
+		if s.cache.heatmap != nil {
+			s.cache.heatmap.evict(s.index)
+		}
+		if s.cache.tracker != nil {
+			s.cache.tracker.evict(s.blockAddress(toReplace), s.index, evicted)
+		}
+		if s.cache.debug {
+			s.log(slog.LevelDebug, "evict", slog.Uint64("tag", uint64(toReplace)), addressAttr(s.blockAddress(toReplace)))
+		}
+		s.cache.onEvict(s.blockAddress(toReplace), evicted)
+	}
+	// put ourself into the way
+	s.ways[tag] = val
+	s.rePol.miss(tag)
+	if s.idle != nil {
+		s.idle.miss(tag)
+	}
+	if s.cache.tracker != nil {
+		s.cache.tracker.fill(address)
+	}
+	s.cache.onFill(address)
+	return val, nil
+}
+
+// invalidate removes the block holding this address from the set, it returns false if the block is not in the set
+func (s *set) invalidate(address uint32) bool {
+	tag := address >> (ADDRESSLENGTH - s.cache.tagSize)
+	if _, ok := s.ways[tag]; !ok {
+		return false
+	}
+	delete(s.ways, tag)
+	s.rePol.remove(tag)
+	if s.idle != nil {
+		s.idle.remove(tag)
+	}
+	if s.cache.tracker != nil {
+		s.cache.tracker.forget(address & ^s.cache.offsetMask)
+	}
+	return true
+}
+
+// flush removes all the blocks of the set
+func (s *set) flush() {
+	s.ways = make(map[uint32][]byte)
+	s.rePol = s.newRePol(s.pol)
+	if s.idle != nil {
+		s.idle = s.newRePol(s.idlePolicy())
+	}
+}
+
+// load reads the block at this address from the source
+func (s *set) load(address uint32) ([]byte, error) {
+	if s.cache.source == nil { // only tags are kept
+		return nil, nil
 	}
 	// create new tags and data
 	val := make([]byte, s.cache.blockSize+1) // one for edition bits
 	val[0] = 0b0000_0000                     // edition bits TODO: implement it
 	n, err := s.cache.source.ReadAt(val[1:], int64(address))
+	atomic.AddUint64(&s.stats.bytesRead, uint64(n))
 	if err != nil || n < len(val[1:]) {
 		if err == io.EOF {
 			// write it at the end
 			copy(val[n+1:], "EOF") // consider EOF
 		} else {
-			log.Fatalf("Read from file failed: %s, %b", err, n)
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, errors.New(fmt.Sprintf("CACHE: Cannot read the block at %#x, %d bytes read: %s", address, n, err))
 		}
 	}
-	// put ourself into the way
-	s.ways[tag] = val
+	return val, nil
+}
+
+// log logs a message about this set with the attributes of the set
+func (s *set) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	attrs = append(attrs, slog.Uint64("set", uint64(s.index)), slog.String("policy", s.pol.String()))
+	s.cache.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// addressAttr gives the attribute of an address, in hexadecimal
+func addressAttr(address uint32) slog.Attr {
+	return slog.String("address", fmt.Sprintf("%#x", address))
+}
+
+// blockAddress gives the address of the first byte of the block stored in this set with this tag
+func (s *set) blockAddress(tag uint32) uint32 {
+	return tag<<(ADDRESSLENGTH-s.cache.tagSize) | s.index<<s.cache.offsetSize
 }
 
 // repol interface represent the capabilities of a replacement policy implementation
@@ -94,4 +284,17 @@ type repol interface {
 	miss(tag uint32)
 	// toReplace gives the tag present in the cache to replace
 	toReplace() uint32
+	// victim gives the tag toReplace would give, without changing the state of the policy
+	victim() uint32
+	// remove forgets a resident tag invalidated in the cache
+	remove(tag uint32)
+	// describe gives the state of the resident tags, in the order the policy would replace them as far as it is known
+	describe() []policyLine
+}
+
+// policyLine is the state of a resident tag in a replacement policy
+type policyLine struct {
+	tag   uint32
+	queue string // queue of the policy holding the tag, empty if the policy has only one
+	value int    // policy specific value: frequency, RRPV or next use
 }
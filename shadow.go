@@ -0,0 +1,58 @@
+package gimc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// shadow is a cache keeping only tags, fed with the addresses of the live cache
+type shadow struct {
+	name     string
+	geometry Geometry
+	cache    *Cache
+}
+
+// WithShadow attaches a shadow cache of this geometry to the cache. A shadow keeps only the tags, it never reads
+// the datasource, and is given every address accessed in the cache to tell what its hit rate would have been
+// (see ShadowStats). The options are those of the shadow, for instance WithTinyLFU.
+// The shadows are updated during the accesses to the cache, each one adding the cost of a tag lookup to Get.
+// OPT cannot be used as the future accesses are unknown.
+func WithShadow(name string, g Geometry, opts ...Option) Option {
+	return func(c *Cache) error {
+		for _, s := range c.shadows {
+			if s.name == name {
+				return errors.New(fmt.Sprintf("shadow %s already exists", name))
+			}
+		}
+		cache, err := newCache(g.Sets, g.BlockSize, 1, g.Ways, nil, g.Policy, opts...)
+		if err != nil {
+			return errors.New(fmt.Sprintf("shadow %s: %s", name, err))
+		}
+		c.shadows = append(c.shadows, &shadow{name: name, geometry: g, cache: cache})
+		return nil
+	}
+}
+
+// ShadowStats are the statistics of a shadow cache
+type ShadowStats struct {
+	SetStats
+	Geometry
+	Name string `json:"name"`
+}
+
+// HitRate gives the hits over the accesses of the shadow
+func (s ShadowStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// ShadowStats gives the statistics of the shadow caches, in the order of their options
+func (c *Cache) ShadowStats() []ShadowStats {
+	var stats []ShadowStats
+	for _, s := range c.shadows {
+		stats = append(stats, ShadowStats{SetStats: s.cache.Stats().SetStats, Geometry: s.geometry, Name: s.name})
+	}
+	return stats
+}
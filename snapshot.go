@@ -0,0 +1,112 @@
+package gimc
+
+import "sort"
+
+// LineSnapshot is a line of a set in a Snapshot
+type LineSnapshot struct {
+	Tag     uint32 `json:"tag"`
+	Address uint32 `json:"address"` // address of the first byte of the block
+	Valid   bool   `json:"valid"`
+	Dirty   bool   `json:"dirty"`
+	// Rank is the position of the line in the replacement order of the policy, 0 for the next victim:
+	// the oldest line for FIFO, the least recently used one for LRU. It is -1 if the policy does not know the line.
+	Rank  int    `json:"rank"`
+	Queue string `json:"queue,omitempty"` // queue of the policy holding the line: a1in or am for 2Q, small or main for S3-FIFO, lir or hir for LIRS
+	Value int    `json:"value"`           // frequency for S3-FIFO, RRPV for RRIP, next use in the trace for OPT
+	Data  []byte `json:"data,omitempty"`  // copy of the block, nil if the cache only keeps tags
+}
+
+// SetSnapshot is the content of a set in a Snapshot
+type SetSnapshot struct {
+	Index     uint32         `json:"index"`
+	Policy    RePol          `json:"policy"`
+	Ways      uint16         `json:"ways"`      // maximum number of lines
+	Occupancy int            `json:"occupancy"` // number of lines
+	Lines     []LineSnapshot `json:"lines"`     // by rank
+}
+
+// RegionSnapshot is the content of the sets of a region (see WithRegionPolicy)
+type RegionSnapshot struct {
+	Start uint32        `json:"start"`
+	End   uint32        `json:"end"`
+	Sets  []SetSnapshot `json:"sets"`
+}
+
+// Snapshot is a copy of the content of the cache
+type Snapshot struct {
+	Lines    int              `json:"lines"`    // number of lines of the cache
+	Capacity int              `json:"capacity"` // maximum number of lines
+	Sets     []SetSnapshot    `json:"sets"`
+	Regions  []RegionSnapshot `json:"regions,omitempty"`
+}
+
+// Snapshot gives a consistent copy of the content of the cache, the accesses being blocked during the copy.
+// It copies every block, it is meant to debug the cache and not to be called at each access.
+func (c *Cache) Snapshot() Snapshot {
+	return c.snapshot(true)
+}
+
+// SnapshotState gives the same copy as Snapshot without the data of the blocks, only the state of the lines.
+// It is much faster on big blocks and is the one to serve on a debug page.
+func (c *Cache) SnapshotState() Snapshot {
+	return c.snapshot(false)
+}
+
+// snapshot gives a copy of the content of the cache, with the data of the blocks if withData is true
+func (c *Cache) snapshot(withData bool) Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := Snapshot{Capacity: c.capacity()}
+	snapshotSets := func(sets []*set) []SetSnapshot {
+		snapshots := make([]SetSnapshot, len(sets))
+		for i, s := range sets {
+			snapshots[i] = s.snapshot(withData)
+			snapshot.Lines += snapshots[i].Occupancy
+		}
+		return snapshots
+	}
+	snapshot.Sets = snapshotSets(c.sets)
+	for _, r := range c.regions {
+		snapshot.Regions = append(snapshot.Regions, RegionSnapshot{Start: r.start, End: r.end, Sets: snapshotSets(r.sets)})
+	}
+	return snapshot
+}
+
+// snapshot gives a copy of the content of the set, the cache must be locked
+func (s *set) snapshot(withData bool) SetSnapshot {
+	snapshot := SetSnapshot{Index: s.index, Policy: s.pol, Ways: s.maxWays, Occupancy: len(s.ways)}
+	described := make(map[uint32]bool, len(s.ways))
+	for rank, pl := range s.rePol.describe() {
+		if _, ok := s.ways[pl.tag]; !ok {
+			continue
+		}
+		described[pl.tag] = true
+		line := s.lineSnapshot(pl.tag, rank, withData)
+		line.Queue = pl.queue
+		line.Value = pl.value
+		snapshot.Lines = append(snapshot.Lines, line)
+	}
+	var unknown []uint32
+	for tag := range s.ways {
+		if !described[tag] {
+			unknown = append(unknown, tag)
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+	for _, tag := range unknown {
+		snapshot.Lines = append(snapshot.Lines, s.lineSnapshot(tag, -1, withData))
+	}
+	return snapshot
+}
+
+func (s *set) lineSnapshot(tag uint32, rank int, withData bool) LineSnapshot {
+	line := LineSnapshot{Tag: tag, Address: s.blockAddress(tag), Valid: true, Rank: rank}
+	if val := s.ways[tag]; val != nil {
+		line.Valid = val[0]&DELETED == 0
+		line.Dirty = val[0]&MODIFIED != 0
+		if withData {
+			line.Data = append([]byte(nil), val[1:]...)
+		}
+	}
+	return line
+}
@@ -0,0 +1,100 @@
+package gimc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	src := newMemSource(1 << 16)
+	// 2 sets of 2 ways
+	cache, err := CreateCache(2, 64, 8, 2, src, LRU, WithRegionPolicy(0x8000, 0x9000, S3FIFO, 4))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	cache.Get(0)
+	cache.Get(128)
+	cache.Get(0) // 128 is now the least recently used line of set 0
+	cache.Get(64)
+	cache.Get(0x8000)
+	cache.Get(0x8000)
+
+	snapshot := cache.Snapshot()
+	if snapshot.Lines != 4 || snapshot.Capacity != 12 || len(snapshot.Sets) != 2 || len(snapshot.Regions) != 1 {
+		t.Fatal(fmt.Sprintf("Wrong snapshot: %+v", snapshot))
+	}
+	set := snapshot.Sets[0]
+	if set.Policy != LRU || set.Ways != 2 || set.Occupancy != 2 || len(set.Lines) != 2 {
+		t.Fatal(fmt.Sprintf("Wrong set: %+v", set))
+	}
+	for rank, address := range []uint32{128, 0} {
+		line := set.Lines[rank]
+		if line.Address != address || line.Rank != rank || !line.Valid || line.Dirty || !bytes.Equal(line.Data, sourceBytes(address, 64)) {
+			t.Fatal(fmt.Sprintf("Wrong line %d: %+v", rank, line))
+		}
+	}
+	if line := snapshot.Regions[0].Sets[0].Lines[0]; line.Address != 0x8000 || line.Queue != "small" || line.Value != 1 {
+		t.Fatal(fmt.Sprintf("Wrong line of the region: %+v", line))
+	}
+
+	state := cache.SnapshotState()
+	for _, set := range snapshot.Sets {
+		for i := range set.Lines {
+			set.Lines[i].Data = nil
+		}
+	}
+	for _, set := range snapshot.Regions[0].Sets {
+		for i := range set.Lines {
+			set.Lines[i].Data = nil
+		}
+	}
+	if !reflect.DeepEqual(state, snapshot) {
+		t.Fatal(fmt.Sprintf("The state must be the snapshot without the data: %+v", state))
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil || !strings.Contains(string(data), `"policy":"LRU"`) || !strings.Contains(string(data), `"policy":"S3-FIFO"`) {
+		t.Fatal(fmt.Sprintf("Cannot encode the snapshot (%v): %s", err, data))
+	}
+	var decoded Snapshot
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Regions[0].Sets[0].Policy != S3FIFO {
+		t.Fatal(fmt.Sprintf("Cannot decode the snapshot: %v", err))
+	}
+}
+
+func TestSnapshotPolicies(t *testing.T) {
+	src := newMemSource(1 << 16)
+	trace := make([]uint32, 1000)
+	for i := range trace {
+		trace[i] = uint32(rand.Intn(src.Len()/8)) * 8
+	}
+	policies := map[string]RePol{"OPT": OPT}
+	for name, pol := range allPolicies {
+		policies[name] = pol
+	}
+	for name, pol := range policies {
+		cache, err := CreateCache(4, 64, 8, 8, src, pol, WithTrace(trace))
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+		}
+		for _, address := range trace {
+			cache.Get(address)
+		}
+		// every resident line is known by the policy, in a single order
+		for _, set := range cache.Snapshot().Sets {
+			for rank, line := range set.Lines {
+				if line.Rank != rank {
+					t.Fatal(fmt.Sprintf("%s: wrong rank of %+v in set %d", name, line, set.Index))
+				}
+			}
+			if set.Occupancy != 8 || len(set.Lines) != 8 {
+				t.Fatal(fmt.Sprintf("%s: wrong occupancy of set %d: %d", name, set.Index, set.Occupancy))
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package gimc
+
+import "sync/atomic"
+
+// counters are the statistics of a set. They are updated atomically so that they can be read
+// while the cache is used.
+type counters struct {
+	hits, misses, evictions, bytesRead uint64
+	policy                             int32 // replacement policy currently used by the set
+}
+
+func (c *counters) snapshot() SetStats {
+	return SetStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		BytesRead: atomic.LoadUint64(&c.bytesRead),
+	}
+}
+
+func (c *counters) reset() {
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.evictions, 0)
+	atomic.StoreUint64(&c.bytesRead, 0)
+}
+
+// setPolicy records the replacement policy currently used by the set
+func (c *counters) setPolicy(pol RePol) {
+	atomic.StoreInt32(&c.policy, int32(pol))
+}
+
+func (c *counters) getPolicy() RePol {
+	return RePol(atomic.LoadInt32(&c.policy))
+}
+
+// SetStats are the statistics of a set, or of the whole cache
+type SetStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`  // lines replaced by another one
+	BytesRead uint64 `json:"bytes_read"` // bytes read from the datasource
+}
+
+func (s *SetStats) add(other SetStats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Evictions += other.Evictions
+	s.BytesRead += other.BytesRead
+}
+
+// RegionStats are the statistics of the sets of a region (see WithRegionPolicy)
+type RegionStats struct {
+	Start uint32     `json:"start"`
+	End   uint32     `json:"end"`
+	Sets  []SetStats `json:"sets"`
+}
+
+// PolicyStats are the statistics of the sets using a replacement policy
+type PolicyStats struct {
+	SetStats
+	Policy RePol `json:"policy"`
+	Sets   int   `json:"sets"` // number of sets currently using the policy
+}
+
+// Stats is a snapshot of the statistics of the cache
+type Stats struct {
+	SetStats               // totals of the cache, regions included
+	Sets     []SetStats    `json:"sets"`                   // statistics of each set, by index
+	Regions  []RegionStats `json:"regions"`                // statistics of the sets of each region
+	Policies []PolicyStats `json:"policies"`               // statistics of the sets by replacement policy, in the order of RePol
+	Classes  *MissClasses  `json:"miss_classes,omitempty"` // only WithMissClassification
+	Shadows  []ShadowStats `json:"shadows,omitempty"`      // statistics of the shadow caches (see WithShadow)
+	Source   *SourceStats  `json:"source,omitempty"`       // only with an InstrumentedDatasource
+}
+
+// Stats gives a snapshot of the statistics of the cache. It does not stop the accesses to the cache,
+// each counter is read atomically but the counters may be updated during the snapshot.
+func (c *Cache) Stats() Stats {
+	stats := Stats{Sets: make([]SetStats, len(c.sets))}
+	policies := make(map[RePol]*PolicyStats)
+	// addSet adds the statistics of the set to the totals and to its policy
+	addSet := func(s *set) SetStats {
+		setStats := s.stats.snapshot()
+		stats.add(setStats)
+		pol := s.stats.getPolicy()
+		ps, ok := policies[pol]
+		if !ok {
+			ps = &PolicyStats{Policy: pol}
+			policies[pol] = ps
+		}
+		ps.add(setStats)
+		ps.Sets++
+		return setStats
+	}
+	for i, s := range c.sets {
+		stats.Sets[i] = addSet(s)
+	}
+	for _, r := range c.regions {
+		rs := RegionStats{Start: r.start, End: r.end, Sets: make([]SetStats, len(r.sets))}
+		for i, s := range r.sets {
+			rs.Sets[i] = addSet(s)
+		}
+		stats.Regions = append(stats.Regions, rs)
+	}
+	for pol := FIFO; pol <= OPT; pol++ {
+		if ps, ok := policies[pol]; ok {
+			stats.Policies = append(stats.Policies, *ps)
+		}
+	}
+	if c.classifier != nil {
+		classes := c.classifier.snapshot()
+		stats.Classes = &classes
+	}
+	stats.Shadows = c.ShadowStats()
+	if c.instrumented != nil {
+		sourceStats := c.instrumented.Stats()
+		stats.Source = &sourceStats
+	}
+	return stats
+}
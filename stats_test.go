@@ -0,0 +1,137 @@
+package gimc
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	src := newMemSource(1 << 16)
+	cache, err := CreateCache(2, 64, 8, 2, src, FIFO, WithRegionPolicy(1<<15, 1<<16, LRU, 1))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	// 5 blocks in set 0, 3 evictions
+	for i := uint32(0); i < 5; i++ {
+		cache.Get(i * 128)
+	}
+	cache.Get(0)       // miss, evicted
+	cache.Get(4 * 128) // hit
+	cache.Get(64)      // set 1, miss
+	cache.Get(1 << 15) // region, miss
+	stats := cache.Stats()
+	expected := SetStats{Hits: 1, Misses: 8, Evictions: 4, BytesRead: 8 * 64}
+	if stats.SetStats != expected {
+		t.Fatal(fmt.Sprintf("Wrong totals: %+v", stats.SetStats))
+	}
+	if stats.Sets[0].Evictions != 4 || stats.Sets[1].Misses != 1 || stats.Regions[0].Sets[0].Misses != 1 {
+		t.Fatal(fmt.Sprintf("Wrong statistics per set: %+v", stats))
+	}
+	if hits, misses := cache.GetCounters(); hits != 1 || misses != 8 {
+		t.Fatal("GetCounters must give the totals")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { cache.GetCounters() }); allocs != 0 {
+		t.Fatal(fmt.Sprintf("GetCounters must not allocate, got %v allocations", allocs))
+	}
+	cache.ResetCounters()
+	if cache.Stats().SetStats != (SetStats{}) {
+		t.Fatal("Counters must be reset")
+	}
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	src := newMemSource(1 << 16)
+	cache, _ := CreateCache(4, 64, 8, 4, src, LRU)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10_000; i++ {
+				cache.Get(uint32((i*g)%(1<<16)) &^ 7)
+			}
+		}(g)
+	}
+	for i := 0; i < 100; i++ {
+		cache.Stats()
+	}
+	wg.Wait()
+	if hits, misses := cache.GetCounters(); hits+misses != 40_000 {
+		t.Fatal(fmt.Sprintf("Every access must be counted, got %d", hits+misses))
+	}
+}
+
+func TestMissClassification(t *testing.T) {
+	src := newMemSource(1 << 16)
+	// 2 sets of 1 way, capacity of 2 blocks
+	cache, err := CreateCache(2, 64, 8, 1, src, LRU, WithMissClassification())
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	cache.Get(0)   // compulsory
+	cache.Get(128) // compulsory, same set as 0
+	cache.Get(0)   // conflict, a fully associative cache of 2 blocks holds 0 and 128
+	cache.Get(64)  // compulsory
+	cache.Get(192) // compulsory
+	cache.Get(128) // capacity, 4 blocks were accessed since
+	compulsory, capacity, conflict := cache.GetMissClasses()
+	if compulsory != 4 || capacity != 1 || conflict != 1 {
+		t.Fatal(fmt.Sprintf("Wrong classification: %d, %d, %d", compulsory, capacity, conflict))
+	}
+	if classes := cache.Stats().Classes; classes == nil || classes.Conflict != 1 {
+		t.Fatal("Stats must give the classification")
+	}
+
+	// a block removed from the cache must be read again whatever the geometry, it is not a conflict miss
+	cache.Get(64) // capacity
+	cache.Invalidate(64)
+	cache.Get(64) // compulsory
+	cache.Get(128) // hit
+	cache.Flush()
+	cache.Get(128) // compulsory
+	if compulsory, capacity, conflict := cache.GetMissClasses(); compulsory != 6 || capacity != 2 || conflict != 1 {
+		t.Fatal(fmt.Sprintf("Wrong classification after invalidation and flush: %d, %d, %d", compulsory, capacity, conflict))
+	}
+}
+
+func TestShadows(t *testing.T) {
+	src := newMemSource(1 << 16)
+	same := Geometry{Sets: 4, Ways: 2, BlockSize: 64, Policy: LRU}
+	cache, err := CreateCache(4, 64, 8, 2, src, LRU,
+		WithShadow("same", same),
+		WithShadow("bigger", Geometry{Sets: 8, Ways: 4, BlockSize: 64, Policy: S3FIFO}))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	// a loop over 16 blocks thrashes 8 lines but fits in 32
+	for i := 0; i < 4; i++ {
+		accessBlocks(cache, 0, 16)
+	}
+	stats := cache.Stats()
+	if len(stats.Shadows) != 2 || stats.Shadows[0].Name != "same" || stats.Shadows[0].Geometry != same {
+		t.Fatal(fmt.Sprintf("Wrong shadows: %+v", stats.Shadows))
+	}
+	if stats.Shadows[0].Hits != stats.Hits || stats.Shadows[0].Misses != stats.Misses {
+		t.Fatal(fmt.Sprintf("A shadow of the same geometry must behave as the cache: %+v, %+v", stats.Shadows[0], stats.SetStats))
+	}
+	if stats.Shadows[0].BytesRead != 0 {
+		t.Fatal("A shadow must not read the datasource")
+	}
+	if bigger := stats.Shadows[1]; bigger.Misses != 16 || bigger.HitRate() != 0.75 {
+		t.Fatal(fmt.Sprintf("The bigger shadow must only miss the first accesses: %+v", bigger))
+	}
+	cache.ResetCounters()
+	if shadows := cache.ShadowStats(); shadows[1].Hits != 0 {
+		t.Fatal("ResetCounters must reset the shadows")
+	}
+
+	for _, opt := range []Option{
+		WithShadow("opt", Geometry{Sets: 4, Ways: 2, BlockSize: 64, Policy: OPT}),
+		WithShadow("same", same),
+	} {
+		if _, err := CreateCache(4, 64, 8, 2, src, LRU, WithShadow("same", same), opt); err == nil {
+			t.Fatal("Must fail with an OPT shadow or a duplicated name")
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package gimc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Geometry is a configuration of cache
+type Geometry struct {
+	Sets      uint16 `json:"sets"`
+	Ways      uint16 `json:"ways"`
+	BlockSize uint16 `json:"block_size"`
+	Policy    RePol  `json:"policy"`
+}
+
+// Footprint gives the memory used by the data of a cache of this geometry, in bytes
+func (g Geometry) Footprint() uint64 {
+	return uint64(g.Sets) * uint64(g.Ways) * (uint64(g.BlockSize) + 1) // one byte for edition bits
+}
+
+// Grid is a set of values for each parameter of a geometry, Sweep evaluates all their combinations
+type Grid struct {
+	Sets       []uint16
+	Ways       []uint16
+	BlockSizes []uint16
+	Policies   []RePol
+}
+
+// Geometries gives all the combinations of the grid, sets varying the slowest and policies the fastest
+func (g Grid) Geometries() []Geometry {
+	var geometries []Geometry
+	for _, sets := range g.Sets {
+		for _, ways := range g.Ways {
+			for _, blockSize := range g.BlockSizes {
+				for _, pol := range g.Policies {
+					geometries = append(geometries, Geometry{Sets: sets, Ways: ways, BlockSize: blockSize, Policy: pol})
+				}
+			}
+		}
+	}
+	return geometries
+}
+
+// SweepResult is the result of the replay of a trace on a geometry
+type SweepResult struct {
+	Geometry
+	Hits, Misses uint64
+}
+
+// HitRate gives the hits over the accesses
+func (r SweepResult) HitRate() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+// MPKA gives the number of misses per thousand accesses
+func (r SweepResult) MPKA() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Misses) * 1000 / float64(r.Hits+r.Misses)
+}
+
+// Sweep replays the trace of addresses on every geometry of the grid, using workers goroutines
+// (the number of CPUs if workers is 0). Only the tags are simulated, no data are read.
+// The options are given to every cache, WithTrace is added for OPT. The results are in the order of Grid.Geometries.
+func Sweep(trace []uint32, grid Grid, workers int, opts ...Option) ([]SweepResult, error) {
+	geometries := grid.Geometries()
+	if len(geometries) == 0 {
+		return nil, errors.New("CACHE: The grid is empty")
+	}
+	for _, g := range geometries {
+		if !isPowerOfTwo(g.Sets) || !isPowerOfTwo(g.BlockSize) || g.Ways == 0 {
+			return nil, errors.New(fmt.Sprintf("CACHE: Invalid geometry %+v, sets and block size must be powers of 2", g))
+		}
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]SweepResult, len(geometries))
+	errs := make([]error, len(geometries))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i], errs[i] = simulate(trace, geometries[i], opts)
+			}
+		}()
+	}
+	for i := range geometries {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// simulate replays the trace on a cache of this geometry keeping only the tags
+func simulate(trace []uint32, g Geometry, opts []Option) (SweepResult, error) {
+	if g.Policy == OPT {
+		opts = append(opts[:len(opts):len(opts)], WithTrace(trace))
+	}
+	c, err := newCache(g.Sets, g.BlockSize, g.BlockSize, g.Ways, nil, g.Policy, opts...)
+	if err != nil {
+		return SweepResult{}, err
+	}
+	for _, address := range trace {
+		c.Get(address)
+	}
+	hits, misses := c.GetCounters()
+	return SweepResult{Geometry: g, Hits: hits, Misses: misses}, nil
+}
+
+func isPowerOfTwo(v uint16) bool {
+	return v != 0 && v&(v-1) == 0
+}
+
+// sweepHeader are the columns of the tables written by WriteSweepCSV and WriteSweepMarkdown
+var sweepHeader = []string{"sets", "ways", "block", "policy", "hits", "misses", "hit_rate", "mpka", "footprint_bytes"}
+
+func sweepRow(r SweepResult) []string {
+	return []string{
+		fmt.Sprint(r.Sets), fmt.Sprint(r.Ways), fmt.Sprint(r.BlockSize), r.Policy.String(),
+		fmt.Sprint(r.Hits), fmt.Sprint(r.Misses),
+		fmt.Sprintf("%.4f", r.HitRate()), fmt.Sprintf("%.2f", r.MPKA()), fmt.Sprint(r.Footprint()),
+	}
+}
+
+// WriteSweepCSV writes the results as CSV, with a header line
+func WriteSweepCSV(w io.Writer, results []SweepResult) error {
+	if _, err := fmt.Fprintln(w, strings.Join(sweepHeader, ",")); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if _, err := fmt.Fprintln(w, strings.Join(sweepRow(r), ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSweepMarkdown writes the results as a Markdown table
+func WriteSweepMarkdown(w io.Writer, results []SweepResult) error {
+	separator := strings.Repeat("| --- ", len(sweepHeader)) + "|"
+	if _, err := fmt.Fprintf(w, "| %s |\n%s\n", strings.Join(sweepHeader, " | "), separator); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(sweepRow(r), " | ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
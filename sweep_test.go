@@ -0,0 +1,62 @@
+package gimc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSweep(t *testing.T) {
+	trace := make([]uint32, 20_000)
+	for i := range trace {
+		trace[i] = uint32(rand.Intn(1 << 14))
+	}
+	grid := Grid{Sets: []uint16{4, 16}, Ways: []uint16{1, 4}, BlockSizes: []uint16{64}, Policies: []RePol{LRU, OPT}}
+	results, err := Sweep(trace, grid, 3)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot sweep: %s", err))
+	}
+	if len(results) != 8 {
+		t.Fatal(fmt.Sprintf("Expected 8 results, got %d", len(results)))
+	}
+	src := newMemSource(1 << 15)
+	for i, g := range grid.Geometries() {
+		r := results[i]
+		if r.Geometry != g || r.Hits+r.Misses != uint64(len(trace)) {
+			t.Fatal(fmt.Sprintf("Wrong result %d: %+v", i, r))
+		}
+		expected := r.Hits
+		if g.Policy == OPT {
+			_, expected, _, _ = OptimalHitRate(g.Sets, g.BlockSize, g.Ways, trace)
+		} else {
+			cache, _ := CreateCache(g.Sets, g.BlockSize, 1, g.Ways, src, g.Policy)
+			for _, address := range trace {
+				cache.Get(address)
+			}
+			expected, _ = cache.GetCounters()
+		}
+		if r.Hits != expected {
+			t.Fatal(fmt.Sprintf("Result %d has %d hits, expected %d", i, r.Hits, expected))
+		}
+	}
+
+	var csv, md bytes.Buffer
+	_ = WriteSweepCSV(&csv, results)
+	_ = WriteSweepMarkdown(&md, results)
+	csvLines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(csvLines) != 9 || csvLines[0] != "sets,ways,block,policy,hits,misses,hit_rate,mpka,footprint_bytes" {
+		t.Fatal(fmt.Sprintf("Wrong CSV:\n%s", csv.String()))
+	}
+	if !strings.HasPrefix(csvLines[1], "4,1,64,LRU,") || !strings.HasSuffix(csvLines[1], ",260") {
+		t.Fatal(fmt.Sprintf("Wrong CSV row %s", csvLines[1]))
+	}
+	if mdLines := strings.Split(strings.TrimSpace(md.String()), "\n"); len(mdLines) != 10 {
+		t.Fatal(fmt.Sprintf("Wrong Markdown:\n%s", md.String()))
+	}
+
+	if _, err := Sweep(trace, Grid{Sets: []uint16{3}, Ways: []uint16{1}, BlockSizes: []uint16{64}, Policies: []RePol{LRU}}, 1); err == nil {
+		t.Fatal("Sets must be a power of 2")
+	}
+}
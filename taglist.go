@@ -0,0 +1,131 @@
+package gimc
+
+// tagNode is an entry of a tagList
+type tagNode struct {
+	tag        uint32
+	val        uint8 // policy specific metadata (frequency, state...)
+	prev, next *tagNode
+}
+
+// tagList is a doubly linked list of tags with O(1) lookup, insertion and removal.
+// The front of the list is the oldest entry and the back the newest one.
+// A removed node is recycled by the next insertion in the same list, it must not be kept by the caller.
+type tagList struct {
+	root  tagNode // sentinel, root.next is the front and root.prev the back
+	nodes map[uint32]*tagNode
+	spare *tagNode // last removed node, reused by the next insertion to avoid an allocation
+}
+
+// newTagList creates an empty list of tags
+func newTagList() *tagList {
+	l := &tagList{nodes: make(map[uint32]*tagNode)}
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	return l
+}
+
+func (l *tagList) len() int {
+	return len(l.nodes)
+}
+
+// get gives the node of the tag, nil if the tag is not in the list
+func (l *tagList) get(tag uint32) *tagNode {
+	return l.nodes[tag]
+}
+
+func (l *tagList) contains(tag uint32) bool {
+	_, ok := l.nodes[tag]
+	return ok
+}
+
+// front gives the oldest node of the list, nil if empty
+func (l *tagList) front() *tagNode {
+	if len(l.nodes) == 0 {
+		return nil
+	}
+	return l.root.next
+}
+
+// back gives the newest node of the list, nil if empty
+func (l *tagList) back() *tagNode {
+	if len(l.nodes) == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// next gives the node following n (newer than n), nil if n is the back of the list
+func (l *tagList) next(n *tagNode) *tagNode {
+	if n.next == &l.root {
+		return nil
+	}
+	return n.next
+}
+
+// pushBack adds the tag at the back of the list. The tag must not already be in the list.
+func (l *tagList) pushBack(tag uint32) *tagNode {
+	n := l.spare
+	if n != nil {
+		l.spare = nil
+		*n = tagNode{tag: tag}
+	} else {
+		n = &tagNode{tag: tag}
+	}
+	l.insertBefore(n, &l.root)
+	l.nodes[tag] = n
+	return n
+}
+
+// moveToBack moves an existing node at the back of the list
+func (l *tagList) moveToBack(n *tagNode) {
+	if l.root.prev == n {
+		return
+	}
+	l.unlink(n)
+	l.insertBefore(n, &l.root)
+}
+
+// remove removes the tag from the list, returns false if the tag was not present
+func (l *tagList) remove(tag uint32) bool {
+	n, ok := l.nodes[tag]
+	if !ok {
+		return false
+	}
+	l.unlink(n)
+	delete(l.nodes, tag)
+	l.spare = n
+	return true
+}
+
+// popFront removes and returns the oldest node of the list, nil if empty
+func (l *tagList) popFront() *tagNode {
+	n := l.front()
+	if n != nil {
+		l.unlink(n)
+		delete(l.nodes, n.tag)
+		l.spare = n
+	}
+	return n
+}
+
+func (l *tagList) insertBefore(n, at *tagNode) {
+	n.prev = at.prev
+	n.next = at
+	at.prev.next = n
+	at.prev = n
+}
+
+func (l *tagList) unlink(n *tagNode) {
+	n.prev.next = n.next
+	n.next.prev = n.prev
+	n.prev = nil
+	n.next = nil
+}
+
+// describeList appends the tags of the list to lines, from the front, with their value and this queue
+func describeList(l *tagList, queue string, lines []policyLine) []policyLine {
+	for n := l.front(); n != nil; n = l.next(n) {
+		lines = append(lines, policyLine{tag: n.tag, queue: queue, value: int(n.val)})
+	}
+	return lines
+}
@@ -0,0 +1,130 @@
+package gimc
+
+const (
+	tinyLFUDepth      = 4  // number of rows of the count-min sketch
+	tinyLFUMaxCount   = 15 // counters are saturated at 4 bits
+	tinyLFUSampleMult = 10 // the sketch is aged every tinyLFUSampleMult * capacity accesses
+)
+
+// tinyLFUSeeds are used to get independent hashes for the rows of the sketch and the doorkeeper
+var tinyLFUSeeds = [tinyLFUDepth]uint32{0x9747b28c, 0x85ebca6b, 0xc2b2ae35, 0x27d4eb2f}
+
+// tinyLFU is an admission filter estimating the access frequency of the blocks with a count-min sketch.
+// The first access of a block only sets its bits in the doorkeeper (bloom filter), so that blocks seen once
+// do not pollute the sketch. Every sampleSize accesses, the counters are halved and the doorkeeper is cleared
+// so that the estimation follows the changes of the workload.
+// A missed block is admitted only if it is estimated more frequent than the victim of the replacement policy.
+type tinyLFU struct {
+	sketch     [tinyLFUDepth][]uint8
+	sketchMask uint32
+	doorkeeper []uint64
+	doorMask   uint32
+	additions  uint32 // number of accesses since the last reset
+	sampleSize uint32
+}
+
+// newTinyLFU creates a filter for a cache able to hold capacity blocks
+func newTinyLFU(capacity uint32) *tinyLFU {
+	width := nextPowerOfTwo(capacity)
+	if width < 16 {
+		width = 16
+	}
+	sampleSize := tinyLFUSampleMult * capacity
+	doorBits := nextPowerOfTwo(sampleSize)
+	if doorBits < 64 {
+		doorBits = 64
+	}
+	t := &tinyLFU{
+		sketchMask: width - 1,
+		doorkeeper: make([]uint64, doorBits/64),
+		doorMask:   doorBits - 1,
+		sampleSize: sampleSize,
+	}
+	for i := range t.sketch {
+		t.sketch[i] = make([]uint8, width)
+	}
+	return t
+}
+
+// record counts an access to the block at this address
+func (t *tinyLFU) record(block uint32) {
+	if !t.inDoorkeeper(block) {
+		t.addDoorkeeper(block)
+	} else {
+		for i := range t.sketch {
+			counter := &t.sketch[i][hash32(block, tinyLFUSeeds[i])&t.sketchMask]
+			if *counter < tinyLFUMaxCount {
+				*counter++
+			}
+		}
+	}
+	t.additions++
+	if t.additions >= t.sampleSize {
+		t.reset()
+	}
+}
+
+// estimate gives the estimated frequency of the block
+func (t *tinyLFU) estimate(block uint32) uint8 {
+	min := uint8(tinyLFUMaxCount)
+	for i := range t.sketch {
+		if c := t.sketch[i][hash32(block, tinyLFUSeeds[i])&t.sketchMask]; c < min {
+			min = c
+		}
+	}
+	if t.inDoorkeeper(block) {
+		min++
+	}
+	return min
+}
+
+// admit tells if the candidate block must replace the victim block
+func (t *tinyLFU) admit(candidate, victim uint32) bool {
+	return t.estimate(candidate) > t.estimate(victim)
+}
+
+// reset ages the sketch by halving all the counters and clears the doorkeeper
+func (t *tinyLFU) reset() {
+	for i := range t.sketch {
+		for j := range t.sketch[i] {
+			t.sketch[i][j] >>= 1
+		}
+	}
+	for i := range t.doorkeeper {
+		t.doorkeeper[i] = 0
+	}
+	t.additions /= 2
+}
+
+func (t *tinyLFU) inDoorkeeper(block uint32) bool {
+	h1 := hash32(block, tinyLFUSeeds[0]) & t.doorMask
+	h2 := hash32(block, tinyLFUSeeds[1]) & t.doorMask
+	return t.doorkeeper[h1/64]&(1<<(h1%64)) != 0 && t.doorkeeper[h2/64]&(1<<(h2%64)) != 0
+}
+
+func (t *tinyLFU) addDoorkeeper(block uint32) {
+	h1 := hash32(block, tinyLFUSeeds[0]) & t.doorMask
+	h2 := hash32(block, tinyLFUSeeds[1]) & t.doorMask
+	t.doorkeeper[h1/64] |= 1 << (h1 % 64)
+	t.doorkeeper[h2/64] |= 1 << (h2 % 64)
+}
+
+// hash32 mixes the value with the seed (murmur3 finalizer)
+func hash32(v, seed uint32) uint32 {
+	h := v ^ seed
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// nextPowerOfTwo gives the smallest power of two greater or equal to v
+func nextPowerOfTwo(v uint32) uint32 {
+	p := uint32(1)
+	for p < v {
+		p <<= 1
+	}
+	return p
+}
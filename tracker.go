@@ -0,0 +1,103 @@
+package gimc
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// lineTracker counts the hits of the resident blocks and remembers the last evictions
+type lineTracker struct {
+	hits      map[uint32]uint64 // hits of each resident block since its fill, by block address
+	evictions []Eviction        // ring of the last evictions
+	next      int               // position of the next eviction in the ring
+}
+
+// WithLineTracking counts the hits of each resident block (see HotBlocks) and remembers the last evictions
+// (see RecentEvictions). It adds a map update to each hit and fill.
+func WithLineTracking(evictions int) Option {
+	return func(c *Cache) error {
+		if evictions <= 0 {
+			return errors.New("the number of evictions kept must be positive")
+		}
+		c.tracker = &lineTracker{hits: make(map[uint32]uint64), evictions: make([]Eviction, 0, evictions)}
+		return nil
+	}
+}
+
+func (t *lineTracker) hit(block uint32) {
+	t.hits[block]++
+}
+
+func (t *lineTracker) fill(block uint32) {
+	t.hits[block] = 0
+}
+
+func (t *lineTracker) evict(block, index uint32, data []byte) {
+	e := Eviction{Address: block, Set: index, Hits: t.hits[block], Dirty: data != nil && data[0]&MODIFIED != 0, Time: time.Now()}
+	delete(t.hits, block)
+	if len(t.evictions) < cap(t.evictions) {
+		t.evictions = append(t.evictions, e)
+		return
+	}
+	t.evictions[t.next] = e
+	t.next = (t.next + 1) % len(t.evictions)
+}
+
+// forget forgets an invalidated block
+func (t *lineTracker) forget(block uint32) {
+	delete(t.hits, block)
+}
+
+// HotBlock is a resident block with its number of hits since it was put in the cache
+type HotBlock struct {
+	Address uint32 `json:"address"`
+	Hits    uint64 `json:"hits"`
+}
+
+// Eviction is a block evicted from the cache
+type Eviction struct {
+	Address uint32    `json:"address"`
+	Set     uint32    `json:"set"`
+	Hits    uint64    `json:"hits"` // hits while the block was in the cache
+	Dirty   bool      `json:"dirty"`
+	Time    time.Time `json:"time"`
+}
+
+// HotBlocks gives the n resident blocks with the most hits, the hottest first. It is empty without WithLineTracking.
+func (c *Cache) HotBlocks(n int) []HotBlock {
+	if c.tracker == nil || n <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	hot := make([]HotBlock, 0, len(c.tracker.hits))
+	for address, hits := range c.tracker.hits {
+		hot = append(hot, HotBlock{Address: address, Hits: hits})
+	}
+	c.mu.Unlock()
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].Hits != hot[j].Hits {
+			return hot[i].Hits > hot[j].Hits
+		}
+		return hot[i].Address < hot[j].Address
+	})
+	if n < len(hot) {
+		hot = hot[:n]
+	}
+	return hot
+}
+
+// RecentEvictions gives the last evictions, the most recent first. It is empty without WithLineTracking.
+func (c *Cache) RecentEvictions() []Eviction {
+	if c.tracker == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.tracker
+	evictions := make([]Eviction, 0, len(t.evictions))
+	for i := 1; i <= len(t.evictions); i++ {
+		evictions = append(evictions, t.evictions[(t.next-i+len(t.evictions))%len(t.evictions)])
+	}
+	return evictions
+}
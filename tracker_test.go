@@ -0,0 +1,34 @@
+package gimc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLineTracking(t *testing.T) {
+	src := newMemSource(1 << 16)
+	// 1 set of 2 ways
+	cache, err := CreateCache(1, 64, 8, 2, src, LRU, WithLineTracking(2))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Cannot create cache: %s", err))
+	}
+	for _, address := range []uint32{0, 64, 0, 8, 64, 128, 192, 256} {
+		cache.Get(address)
+	}
+	// 0 (2 hits), 64 (1 hit), 128 and 192 evicted, 192 and 256 resident
+	evictions := cache.RecentEvictions()
+	if len(evictions) != 2 || evictions[0].Address != 128 || evictions[1].Address != 64 || evictions[1].Hits != 1 {
+		t.Fatal(fmt.Sprintf("Wrong evictions: %+v", evictions))
+	}
+	cache.Get(192)
+	if hot := cache.HotBlocks(1); len(hot) != 1 || hot[0].Address != 192 || hot[0].Hits != 1 {
+		t.Fatal(fmt.Sprintf("Wrong hot blocks: %+v", hot))
+	}
+	if hot := cache.HotBlocks(-1); hot != nil {
+		t.Fatal(fmt.Sprintf("No block must be given for a negative number: %+v", hot))
+	}
+	cache.Invalidate(192)
+	if hot := cache.HotBlocks(5); len(hot) != 1 || hot[0].Address != 256 {
+		t.Fatal(fmt.Sprintf("An invalidated block cannot be hot: %+v", hot))
+	}
+}
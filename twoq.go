@@ -0,0 +1,86 @@
+package gimc
+
+// twoQ is the structure used to implement and represent the 2Q replacement policy.
+// New tags enter A1in (FIFO), tags evicted from A1in are remembered in A1out (tags only, no data)
+// and only tags referenced again while in A1out are promoted to Am (LRU).
+// A scan therefore only goes through A1in and does not evict the working set kept in Am.
+type twoQ struct {
+	a1in  *tagList // resident tags seen once, FIFO
+	a1out *tagList // ghost tags recently evicted from a1in
+	am    *tagList // resident hot tags, LRU
+	kin   int      // maximum size of a1in before it is used for replacement
+	kout  int      // maximum size of a1out
+}
+
+// newTwoQ creates a 2Q policy for a set of maxWays ways, using the sizes advised in the paper
+// (25% of the ways for A1in, 50% for A1out).
+func newTwoQ(maxWays uint16) *twoQ {
+	return &twoQ{
+		a1in:  newTagList(),
+		a1out: newTagList(),
+		am:    newTagList(),
+		kin:   maxInt(1, int(maxWays)/4),
+		kout:  maxInt(1, int(maxWays)/2),
+	}
+}
+
+func (q *twoQ) toReplace() uint32 {
+	if q.replacesA1in() {
+		// remember the evicted tag in A1out
+		victim := q.a1in.popFront().tag
+		q.a1out.pushBack(victim)
+		if q.a1out.len() > q.kout {
+			q.a1out.popFront()
+		}
+		return victim
+	}
+	return q.am.popFront().tag
+}
+
+func (q *twoQ) victim() uint32 {
+	if q.replacesA1in() {
+		return q.a1in.front().tag
+	}
+	return q.am.front().tag
+}
+
+// replacesA1in tells if the next tag to replace is taken from A1in rather than from Am
+func (q *twoQ) replacesA1in() bool {
+	return q.a1in.len() > q.kin || q.am.len() == 0
+}
+
+func (q *twoQ) hit(tag uint32) {
+	if n := q.am.get(tag); n != nil {
+		q.am.moveToBack(n)
+	}
+	// nothing to do in A1in, it stays a FIFO
+}
+
+func (q *twoQ) miss(tag uint32) {
+	if q.a1out.remove(tag) {
+		// seen recently, it is hot
+		q.am.pushBack(tag)
+	} else {
+		q.a1in.pushBack(tag)
+	}
+}
+
+// maxInt gives the biggest of the two integers
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// describe gives the tags of A1in then of Am, each from the next to be replaced
+func (q *twoQ) describe() []policyLine {
+	return describeList(q.am, "am", describeList(q.a1in, "a1in", nil))
+}
+
+// remove forgets an invalidated tag, it is not remembered in A1out
+func (q *twoQ) remove(tag uint32) {
+	if !q.a1in.remove(tag) {
+		q.am.remove(tag)
+	}
+}